@@ -0,0 +1,155 @@
+package timefmt
+
+import (
+	"errors"
+	"time"
+)
+
+// A Calendar counts years in eras other than those of the proleptic
+// Gregorian calendar, for the directives with the E modifier: %EC names the
+// era and %Ey is the year of the era, and the EraYearFormat, EraDateFormat
+// and EraDateTimeFormat of the locale give %EY, %Ex and %Ec. A locale
+// without a Calendar, or a date before its first era, falls back to %C, %y,
+// %Y, %x and %c.
+type Calendar interface {
+	// Date returns the date of t in its location in the calendar, and
+	// false if it comes before the first era.
+	Date(t time.Time) (CalendarDate, bool)
+	// Gregorian returns the proleptic Gregorian date of d, and false if d
+	// is not a date of its era, such as one after the era ended; the date
+	// is then counted on from the start of the era.
+	Gregorian(d CalendarDate) (Date, bool)
+	// EraNames returns the names of an era, nil past the last one. The
+	// first name is formatted and all of them are parsed.
+	EraNames(era int) []string
+}
+
+// A CalendarDate is a date of a Calendar.
+type CalendarDate struct {
+	Era   int // the index of the era, from 0 for the first
+	Year  int // the year of the era, from 1
+	Month time.Month
+	Day   int
+}
+
+// An Era is an era of an EraCalendar.
+type Era struct {
+	Names []string // the first is formatted and all are parsed
+	Start Date     // the first day of the era, in its year 1
+}
+
+// An EraCalendar is a Calendar whose eras start on days of the Gregorian
+// calendar and whose months and days are Gregorian ones.
+type EraCalendar struct {
+	Eras []Era // in order of their Start
+	name int   // the index of the name formatted
+}
+
+// JapaneseCalendar is the calendar of the Japanese imperial eras from Meiji
+// on, which the Japanese locale uses. Eras change mid-year: 2019-04-30 is
+// 平成31年 and 2019-05-01 令和元年. The eras are also named by their initial,
+// as in R8.10.18, and in romaji; see WithName. Japan has counted days in
+// the Gregorian calendar since Meiji 6 (1873), and earlier Meiji dates are
+// taken to be Gregorian too.
+var JapaneseCalendar = &EraCalendar{Eras: []Era{
+	{Names: []string{"明治", "M", "Meiji"}, Start: Date{1868, time.October, 23}},
+	{Names: []string{"大正", "T", "Taishō", "Taisho"}, Start: Date{1912, time.July, 30}},
+	{Names: []string{"昭和", "S", "Shōwa", "Showa"}, Start: Date{1926, time.December, 25}},
+	{Names: []string{"平成", "H", "Heisei"}, Start: Date{1989, time.January, 8}},
+	{Names: []string{"令和", "R", "Reiwa"}, Start: Date{2019, time.May, 1}},
+}}
+
+// WithName returns a copy of c that formats the i-th name of each era
+// instead of the first, falling back to the first for eras with fewer
+// names: JapaneseCalendar.WithName(1) formats 令和 as R.
+func (c *EraCalendar) WithName(i int) *EraCalendar {
+	cc := *c
+	cc.name = i
+	return &cc
+}
+
+// Date implements Calendar.
+func (c *EraCalendar) Date(t time.Time) (CalendarDate, bool) {
+	year, month, day := t.Date()
+	d := Date{year, month, day}
+	for era := len(c.Eras) - 1; era >= 0; era-- {
+		if start := c.Eras[era].Start; !d.before(start) {
+			return CalendarDate{Era: era, Year: year - start.Year + 1, Month: month, Day: day}, true
+		}
+	}
+	return CalendarDate{}, false
+}
+
+// Gregorian implements Calendar.
+func (c *EraCalendar) Gregorian(d CalendarDate) (Date, bool) {
+	if d.Era < 0 || d.Era >= len(c.Eras) {
+		return Date{}, false
+	}
+	g := Date{c.Eras[d.Era].Start.Year + d.Year - 1, d.Month, d.Day}
+	ok := d.Year >= 1 && g.IsValid() && !g.before(c.Eras[d.Era].Start)
+	if d.Era+1 < len(c.Eras) && !g.before(c.Eras[d.Era+1].Start) {
+		ok = false
+	}
+	return g, ok
+}
+
+// EraNames implements Calendar, putting the name c formats first.
+func (c *EraCalendar) EraNames(era int) []string {
+	if era < 0 || era >= len(c.Eras) {
+		return nil
+	}
+	names := c.Eras[era].Names
+	if c.name <= 0 || c.name >= len(names) {
+		return names
+	}
+	sorted := make([]string, 0, len(names))
+	sorted = append(sorted, names[c.name])
+	sorted = append(sorted, names[:c.name]...)
+	return append(sorted, names[c.name+1:]...)
+}
+
+// eraPattern returns the pattern of l that %EY, %Ex or %Ec stand for, if l
+// has a Calendar. %EY defaults to the name of the era followed by the year,
+// while %Ex and %Ec without patterns of their own are %x and %c. The pattern
+// of %EY may refer neither to %EY nor to the locale patterns, whose own
+// patterns may refer to %EY, so that expanding them always terminates.
+func eraPattern(code rune, l *Locale) (string, bool, error) {
+	if nil == l.Calendar {
+		return "", false, nil
+	}
+	switch code {
+	case 'Y':
+		pattern := l.EraYearFormat
+		if pattern == "" {
+			pattern = "%EC%Ey"
+		}
+		for i := 0; i < len(pattern); i++ {
+			if pattern[i] != '%' {
+				continue
+			}
+			sp, next := cutDirective(pattern, i)
+			switch {
+			case sp.code == 'Y' && sp.mod == 'E', sp.code == 'c', sp.code == 'x', sp.code == 'X', sp.code == 'r':
+				return "", false, errors.New("Recursive locale pattern:" + pattern)
+			}
+			i = next - 1
+		}
+		return pattern, true, nil
+	case 'x':
+		return l.EraDateFormat, l.EraDateFormat != "", nil
+	case 'c':
+		return l.EraDateTimeFormat, l.EraDateTimeFormat != "", nil
+	}
+	return "", false, nil
+}
+
+// before reports whether d comes before e.
+func (d Date) before(e Date) bool {
+	if d.Year != e.Year {
+		return d.Year < e.Year
+	}
+	if d.Month != e.Month {
+		return d.Month < e.Month
+	}
+	return d.Day < e.Day
+}
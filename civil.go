@@ -0,0 +1,122 @@
+package timefmt
+
+import "time"
+
+// A Date is a day of the proleptic Gregorian calendar, without a time of day
+// or a zone.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// A TimeOfDay is a time on a clock, without a date or a zone. Second is 60
+// in a leap second.
+type TimeOfDay struct {
+	Hour       int
+	Minute     int
+	Second     int
+	Nanosecond int
+}
+
+// A DateTime is a date and a time of day without a zone, like a naive
+// datetime of Python.
+type DateTime struct {
+	Date
+	TimeOfDay
+}
+
+// DateOf returns the date of t in its location.
+func DateOf(t time.Time) Date {
+	var d Date
+	d.Year, d.Month, d.Day = t.Date()
+	return d
+}
+
+// TimeOfDayOf returns the time of day of t in its location.
+func TimeOfDayOf(t time.Time) TimeOfDay {
+	var c TimeOfDay
+	c.Hour, c.Minute, c.Second = t.Clock()
+	c.Nanosecond = t.Nanosecond()
+	return c
+}
+
+// DateTimeOf returns the date and time of day of t in its location.
+func DateTimeOf(t time.Time) DateTime {
+	return DateTime{Date: DateOf(t), TimeOfDay: TimeOfDayOf(t)}
+}
+
+// IsValid reports whether d is a day of the calendar: not February 30th.
+func (d Date) IsValid() bool {
+	return DateOf(d.In(time.UTC)) == d
+}
+
+// In returns the midnight that starts d in loc.
+func (d Date) In(loc *time.Location) time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, loc)
+}
+
+// At returns d at the time of day t.
+func (d Date) At(t TimeOfDay) DateTime {
+	return DateTime{Date: d, TimeOfDay: t}
+}
+
+// String returns d in the form 2016-09-22.
+func (d Date) String() string {
+	s, _ := d.Strftime("%Y-%m-%d")
+	return s
+}
+
+// Strftime formats d according to format as StrftimeValue does: %z and %Z
+// are empty and directives of the time of day fail.
+func (d Date) Strftime(format string) (string, error) {
+	return StrftimeValue(d, format)
+}
+
+// IsValid reports whether t is a time on a clock, a leap second included.
+func (t TimeOfDay) IsValid() bool {
+	return 0 <= t.Hour && t.Hour < 24 && 0 <= t.Minute && t.Minute < 60 &&
+		0 <= t.Second && t.Second <= 60 && 0 <= t.Nanosecond && t.Nanosecond < 1e9
+}
+
+// String returns t in the form 06:04:26, followed by as many digits of the
+// fraction of the second as it needs.
+func (t TimeOfDay) String() string {
+	s, _ := t.Strftime("%H:%M:%S")
+	if t.Nanosecond == 0 {
+		return s
+	}
+	f := appendInt(nil, t.Nanosecond, 9)
+	for f[len(f)-1] == '0' {
+		f = f[:len(f)-1]
+	}
+	return s + "." + string(f)
+}
+
+// Strftime formats t according to format as StrftimeValue does: %z and %Z
+// are empty and directives of the date fail.
+func (t TimeOfDay) Strftime(format string) (string, error) {
+	return StrftimeValue(t, format)
+}
+
+// IsValid reports whether dt is a valid date and time of day.
+func (dt DateTime) IsValid() bool {
+	return dt.Date.IsValid() && dt.TimeOfDay.IsValid()
+}
+
+// In returns dt in loc. A second of 60 rolls over as in time.Date.
+func (dt DateTime) In(loc *time.Location) time.Time {
+	return time.Date(dt.Year, dt.Month, dt.Day, dt.Hour, dt.Minute, dt.Second, dt.Nanosecond, loc)
+}
+
+// String returns dt in the form 2016-09-22T06:04:26, followed by the
+// fraction of the second if it has one.
+func (dt DateTime) String() string {
+	return dt.Date.String() + "T" + dt.TimeOfDay.String()
+}
+
+// Strftime formats dt according to format as StrftimeValue does: %z and %Z
+// are empty, and a second of 60 is formatted as such.
+func (dt DateTime) Strftime(format string) (string, error) {
+	return StrftimeValue(dt, format)
+}
@@ -0,0 +1,119 @@
+package timefmt
+
+import "time"
+
+// Components are the fields a value gave when parsed with ParseComponents,
+// before anything the format omits is filled in. Fields tells which of them
+// the value gave; the others are zero.
+type Components struct {
+	Year       int
+	Month      time.Month
+	Day        int
+	Hour       int // 0-23, with %I and %p combined
+	Minute     int
+	Second     int // 60 in a leap second
+	Nanosecond int
+	Weekday    time.Weekday
+	YearDay    int
+	Location   *time.Location // the zone of %z, %Z or %s
+
+	Fields Field
+}
+
+// A Field is a set of the fields of Components.
+type Field uint
+
+const (
+	FieldYear Field = 1 << iota
+	FieldMonth
+	FieldDay
+	FieldHour
+	FieldMinute
+	FieldSecond
+	FieldNanosecond
+	FieldWeekday
+	FieldYearDay
+	FieldZone
+
+	// FieldDate and FieldClock are the fields of a Date and a TimeOfDay.
+	FieldDate  = FieldYear | FieldMonth | FieldDay
+	FieldClock = FieldHour | FieldMinute | FieldSecond | FieldNanosecond
+)
+
+// Has reports whether the value gave all of the fields f.
+func (c Components) Has(f Field) bool {
+	return c.Fields&f == f
+}
+
+// Date returns the date of c and whether the value gave it: year, month
+// and day, from any of the directives that set them.
+func (c Components) Date() (Date, bool) {
+	return Date{Year: c.Year, Month: c.Month, Day: c.Day}, c.Has(FieldDate)
+}
+
+// TimeOfDay returns the time of day of c and whether the value gave one. An
+// hour is enough; the minute, second and nanosecond default to zero.
+func (c Components) TimeOfDay() (TimeOfDay, bool) {
+	return TimeOfDay{Hour: c.Hour, Minute: c.Minute, Second: c.Second, Nanosecond: c.Nanosecond}, c.Has(FieldHour)
+}
+
+// DateTime returns the date and time of day of c and whether the value gave
+// both.
+func (c Components) DateTime() (DateTime, bool) {
+	d, ok := c.Date()
+	t, tok := c.TimeOfDay()
+	return DateTime{Date: d, TimeOfDay: t}, ok && tok
+}
+
+// In returns the time c stands for, in the zone the value named or else in
+// loc. A missing month or day is the first, other missing fields are zero,
+// and a second of 60 rolls over as in time.Date.
+func (c Components) In(loc *time.Location) time.Time {
+	if c.Has(FieldZone) {
+		loc = c.Location
+	}
+	month, day := c.Month, c.Day
+	if !c.Has(FieldMonth) {
+		month = time.January
+	}
+	if !c.Has(FieldDay) {
+		day = 1
+	}
+	return time.Date(c.Year, month, day, c.Hour, c.Minute, c.Second, c.Nanosecond, loc)
+}
+
+// ParseComponents parses value according to format and returns the fields
+// the value gave; see Format.ParseComponents.
+func ParseComponents(value string, format string) (Components, error) {
+	f, e := Compile(format)
+	if nil != e {
+		return Components{}, e
+	}
+	return f.ParseComponents(value)
+}
+
+// components returns the fields of t the value gave.
+func (t *_DateTime) components() Components {
+	c := Components{
+		Year: t.year, Month: t.month, Day: t.day,
+		Hour: t.hour, Minute: t.min, Second: t.sec, Nanosecond: t.nsec,
+		Weekday: t.weekday, YearDay: t.yday,
+	}
+	for _, f := range []struct {
+		has   bool
+		field Field
+	}{
+		{t.hasYear, FieldYear}, {t.hasMonth, FieldMonth}, {t.hasDay, FieldDay},
+		{t.hasHour, FieldHour}, {t.hasMin, FieldMinute}, {t.hasSec, FieldSecond},
+		{t.hasNsec, FieldNanosecond}, {t.hasWeekday, FieldWeekday},
+		{t.hasYday, FieldYearDay}, {t.zone != 0, FieldZone},
+	} {
+		if f.has {
+			c.Fields |= f.field
+		}
+	}
+	if c.Has(FieldZone) {
+		c.Location = t.loc
+	}
+	return c
+}
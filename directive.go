@@ -0,0 +1,196 @@
+package timefmt
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A Directive is a directive added to the built-in ones, such as a fiscal
+// period or a shift code.
+type Directive struct {
+	// Format returns the text of t for the directive. Flags and a width
+	// apply to it as to the names of the built-in directives: the ^ flag
+	// upper cases it and a width of 8 pads it with spaces.
+	Format func(t time.Time) string
+	// ParsePattern is a regular expression matching the text of the
+	// directive in a value. It is matched at the position the directive
+	// parses from, leftmost first; without it, Strptime fails on the
+	// directive with ErrUnsupportedDirective.
+	ParsePattern string
+	// Parse stores the fields the text ParsePattern matched gives into c
+	// and adds them to c.Fields. c holds the fields the directives before
+	// it gave. If Parse is nil, the text is matched and then ignored.
+	Parse func(text string, c *Components) error
+}
+
+// A Dialect is a set of directives registered on top of the built-in ones.
+// Libraries that add directives of their own can keep them in a Dialect
+// rather than in the package-level set of RegisterDirective, so that two of
+// them in one program do not clobber each other's directives.
+//
+// The zero Dialect has only the built-in directives. A Dialect is safe for
+// concurrent use by multiple goroutines and must not be copied after first
+// use.
+type Dialect struct {
+	mu         sync.RWMutex
+	directives map[rune]*directive
+}
+
+// directive is a registered Directive with its pattern compiled.
+type directive struct {
+	Directive
+	re *regexp.Regexp
+}
+
+// registered holds the directives of RegisterDirective, which Strftime,
+// Strptime, Compile and the other functions of the package use.
+var registered Dialect
+
+// RegisterDirective makes d available under %r to the functions of the
+// package. It fails with ErrDirectiveInUse if r is built in or already
+// registered. It is safe to call from multiple goroutines.
+func RegisterDirective(r rune, d Directive) error {
+	return registered.Register(r, d)
+}
+
+// Register makes d available under %r to the Formats the dialect compiles.
+// r must be an ASCII letter or punctuation other than a flag, E, O or a
+// colon. Register fails with ErrDirectiveInUse if r is built in or already
+// registered in the dialect, and fails if d has no Format or an invalid
+// ParsePattern.
+func (dl *Dialect) Register(r rune, d Directive) error {
+	if r <= ' ' || r >= 0x7f || ('0' <= r && r <= '9') || strings.ContainsRune("%-_^#+:EO", r) {
+		return errors.New("Invalid directive:" + string(r))
+	}
+	if nil == d.Format {
+		return errors.New("Directive without Format:" + string(r))
+	}
+	if nil != d.Parse && d.ParsePattern == "" {
+		return errors.New("Directive without ParsePattern:" + string(r))
+	}
+	dir := &directive{Directive: d}
+	if d.ParsePattern != "" {
+		var e error
+		if dir.re, e = regexp.Compile(`^(?:` + d.ParsePattern + `)`); nil != e {
+			return e
+		}
+	}
+	if _, ok := ontput_converters[r]; ok {
+		return ErrDirectiveInUse
+	}
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+	if _, ok := dl.directives[r]; ok {
+		return ErrDirectiveInUse
+	}
+	if nil == dl.directives {
+		dl.directives = map[rune]*directive{}
+	}
+	dl.directives[r] = dir
+	return nil
+}
+
+// lookup returns the directive registered under code, if any.
+func (dl *Dialect) lookup(code rune) (*directive, bool) {
+	if nil == dl {
+		return nil, false
+	}
+	dl.mu.RLock()
+	d, ok := dl.directives[code]
+	dl.mu.RUnlock()
+	return d, ok
+}
+
+// Compile is like the Compile function of the package but with the
+// directives of the dialect. The Format keeps the directives registered
+// when it was compiled.
+func (dl *Dialect) Compile(format string) (*Format, error) {
+	return compile(format, English, dl)
+}
+
+// Strftime is like the Strftime function of the package but with the
+// directives of the dialect.
+func (dl *Dialect) Strftime(t time.Time, format string) (string, error) {
+	var buf [64]byte
+	b, e := appendStrftime(buf[:0], t, format, English, 0, dl)
+	if e != nil {
+		return "", e
+	}
+	return string(b), nil
+}
+
+// Strptime is like the Strptime function of the package but with the
+// directives of the dialect.
+func (dl *Dialect) Strptime(value string, format string) (time.Time, error) {
+	f, e := dl.Compile(format)
+	if nil != e {
+		return time.Time{}, e
+	}
+	return f.Parse(value)
+}
+
+// output is the output converter of d.
+func (d *directive) output(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+	return append(b, d.Format(t)...), nil
+}
+
+// scan returns the length of the text of d at the start of s, or -1.
+func (d *directive) scan(s string) int {
+	if loc := d.re.FindStringIndex(s); nil != loc {
+		return loc[1]
+	}
+	return -1
+}
+
+// input is the input converter of d.
+func (d *directive) input(val string, t *_DateTime, l *Locale) error {
+	if nil == d.Parse {
+		return nil
+	}
+	c := t.components()
+	if e := d.Parse(val, &c); nil != e {
+		return e
+	}
+	t.setComponents(c)
+	return nil
+}
+
+// setComponents sets the fields of t that c has.
+func (t *_DateTime) setComponents(c Components) {
+	if c.Has(FieldYear) {
+		t.year, t.hasYear = c.Year, true
+	}
+	if c.Has(FieldMonth) {
+		t.month, t.hasMonth = c.Month, true
+	}
+	if c.Has(FieldDay) {
+		t.day, t.hasDay = c.Day, true
+	}
+	if c.Has(FieldHour) {
+		t.hour, t.hasHour = c.Hour, true
+	}
+	if c.Has(FieldMinute) {
+		t.min, t.hasMin = c.Minute, true
+	}
+	if c.Has(FieldSecond) {
+		t.sec, t.hasSec = c.Second, true
+	}
+	if c.Has(FieldNanosecond) {
+		t.nsec, t.hasNsec = c.Nanosecond, true
+	}
+	if c.Has(FieldWeekday) {
+		t.weekday, t.hasWeekday = c.Weekday, true
+	}
+	if c.Has(FieldYearDay) {
+		t.yday, t.hasYday = c.YearDay, true
+	}
+	if c.Has(FieldZone) && nil != c.Location {
+		t.loc = c.Location
+		if t.zone == 0 {
+			t.zone = 'z'
+		}
+	}
+}
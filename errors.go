@@ -0,0 +1,107 @@
+package timefmt
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Errors that FormatError and ParseError wrap, for use with errors.Is.
+var (
+	// ErrUnknownDirective is returned for a directive no converter knows.
+	ErrUnknownDirective = errors.New("unknown directive")
+	// ErrUnsupportedDirective is returned for a directive Strftime knows but
+	// Strptime cannot parse, such as a registered one without ParsePattern.
+	ErrUnsupportedDirective = errors.New("directive not supported for parsing")
+	// ErrNoMatch is returned when a value does not match its format.
+	ErrNoMatch = errors.New("value does not match format")
+	// ErrOutOfRange is returned for a field outside its valid range, such
+	// as month 13, day 30 in February or a UTC offset of +25:00.
+	ErrOutOfRange = errors.New("field out of range")
+	// ErrInconsistent is returned when fields naming the same thing
+	// disagree, such as a weekday that is not the weekday of the date or
+	// a directive repeated with different values.
+	ErrInconsistent = errors.New("inconsistent fields")
+	// ErrLeapSecond is returned with the parsed time for a second of 60
+	// under LeapSecondReport.
+	ErrLeapSecond = errors.New("leap second")
+	// ErrMissingField is returned by StrftimeValue for a directive that
+	// needs a field the value does not have, such as %H for a Date, and by
+	// Strptime for a year of an era (%Ey) without the era (%EC).
+	ErrMissingField = errors.New("field missing from value")
+	// ErrUnsupportedValue is returned by StrftimeValue for a value that is
+	// neither an integer nor has the Date, Clock or Zone method of a
+	// Timelike.
+	ErrUnsupportedValue = errors.New("value is not Timelike")
+	// ErrDirectiveInUse is returned by RegisterDirective for a directive
+	// that is built in or already registered.
+	ErrDirectiveInUse = errors.New("directive already in use")
+)
+
+// FormatError describes a problem with a format itself.
+type FormatError struct {
+	Format    string // the format
+	Offset    int    // byte offset of Directive in Format
+	Directive string // the offending directive, such as "%~"
+	Err       error  // ErrUnknownDirective, ErrUnsupportedDirective, ErrMissingField or the converter's error
+}
+
+// Error renders the error with a caret under the offending directive:
+//
+//	timefmt: unknown directive %~
+//	    format: %Y-%~
+//	               ^
+func (e *FormatError) Error() string {
+	return "timefmt: " + e.Err.Error() + " " + e.Directive +
+		caret("format: ", e.Format, e.Offset)
+}
+
+// Unwrap returns e.Err.
+func (e *FormatError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError describes a value Strptime could not parse.
+type ParseError struct {
+	Format       string // the format
+	Value        string // the value being parsed
+	FormatOffset int    // byte offset of Directive in Format
+	ValueOffset  int    // byte offset of Text in Value
+	Directive    string // the directive or literal that failed, "" past the end
+	Expected     string // the pattern Directive matches
+	Text         string // the offending part of Value
+	Err          error  // ErrNoMatch, ErrOutOfRange, ErrInconsistent, ErrLeapSecond or the converter's error
+}
+
+// Error renders the error with carets under the offending part of the value
+// and the directive it failed to match:
+//
+//	timefmt: parsing "13" as %m: field out of range
+//	    value:  2016-13-01
+//	                 ^
+//	    format: %Y-%m-%d
+//	               ^
+func (e *ParseError) Error() string {
+	what := " as " + e.Directive
+	if e.Directive == "" {
+		what = " after the end of the format"
+	}
+	return "timefmt: parsing " + strconv.Quote(e.Text) + what + ": " + e.Err.Error() +
+		caret("value:  ", e.Value, e.ValueOffset) +
+		caret("format: ", e.Format, e.FormatOffset)
+}
+
+// Unwrap returns e.Err.
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// caret returns a labelled line holding s and a line pointing at offset.
+func caret(label string, s string, offset int) string {
+	if offset > len(s) {
+		offset = len(s)
+	}
+	indent := strings.Repeat(" ", 4+len(label)+utf8.RuneCountInString(s[:offset]))
+	return "\n    " + label + s + "\n" + indent + "^"
+}
@@ -0,0 +1,163 @@
+package timefmt
+
+import "time"
+
+// A FiscalYear says how a business divides time into the fiscal years,
+// quarters and periods of %K, %Q and %o. The zero FiscalYear is the
+// calendar year with months as periods.
+type FiscalYear struct {
+	// Start is the month the fiscal year starts in; zero is January.
+	Start time.Month
+	// NamedByStart names a fiscal year after the calendar year it starts
+	// in rather than the one it ends in: from April 2016 to March 2017 is
+	// fiscal year 2016 instead of 2017.
+	NamedByStart bool
+	// Pattern divides the year into periods of whole weeks rather than
+	// months, as in retail calendars.
+	Pattern PeriodPattern
+	// LastWeekday is the day that ends the weeks of a Pattern other than
+	// CalendarMonths. The fiscal year ends on the LastWeekday nearest the
+	// end of the month before Start, so it has 52 weeks or, every five or
+	// six years, 53; the extra week goes to the last period.
+	LastWeekday time.Weekday
+}
+
+// A PeriodPattern is the number of weeks in each period of a quarter.
+type PeriodPattern uint8
+
+const (
+	CalendarMonths PeriodPattern = iota // periods are calendar months
+	Weeks445                            // 4, 4 and 5 weeks
+	Weeks454                            // 4, 5 and 4 weeks
+	Weeks544                            // 5, 4 and 4 weeks
+)
+
+// weeks returns the number of weeks in each period of a quarter.
+func (p PeriodPattern) weeks() [3]int {
+	switch p {
+	case Weeks454:
+		return [3]int{4, 5, 4}
+	case Weeks544:
+		return [3]int{5, 4, 4}
+	}
+	return [3]int{4, 4, 5}
+}
+
+// start returns the first month of the fiscal year.
+func (fy *FiscalYear) start() time.Month {
+	if fy.Start == 0 {
+		return time.January
+	}
+	return fy.Start
+}
+
+// name returns the name of the fiscal year ending in the calendar year end.
+func (fy *FiscalYear) name(end int) int {
+	if fy.NamedByStart && fy.start() != time.January {
+		return end - 1
+	}
+	return end
+}
+
+// endYear returns the calendar year the fiscal year of the given name ends
+// in.
+func (fy *FiscalYear) endYear(name int) int {
+	if fy.NamedByStart && fy.start() != time.January {
+		return name + 1
+	}
+	return name
+}
+
+// Period returns the fiscal year, named as fy says, and the period from 1
+// to 12 that the date of t in its location falls in.
+func (fy *FiscalYear) Period(t time.Time) (year, period int) {
+	y, m, d := t.Date()
+	return fy.period(y, m, d)
+}
+
+// period returns the fiscal year and period of a date.
+func (fy *FiscalYear) period(year int, month time.Month, day int) (int, int) {
+	start := fy.start()
+	end := year
+	if start != time.January && month >= start {
+		end++
+	}
+	if fy.Pattern == CalendarMonths {
+		return fy.name(end), (int(month)-int(start)+12)%12 + 1
+	}
+	n := dayNumber(year, month, day)
+	if n > fy.lastDay(end) {
+		end++
+	} else if n <= fy.lastDay(end-1) {
+		end--
+	}
+	week := (n - fy.lastDay(end-1) - 1) / 7 // 0 to 52
+	q := week / 13
+	if q > 3 {
+		q = 3 // the 53rd week
+	}
+	weeks := fy.Pattern.weeks()
+	p, w := 3*q+1, week-13*q
+	for _, n := range weeks[:2] {
+		if w < n {
+			break
+		}
+		p, w = p+1, w-n
+	}
+	return fy.name(end), p
+}
+
+// PeriodStart returns the first day of the given period of a fiscal year,
+// named as fy says, at midnight in loc. A period outside 1 to 12 counts on
+// into the years before or after.
+func (fy *FiscalYear) PeriodStart(year, period int, loc *time.Location) time.Time {
+	end := fy.endYear(year)
+	start := fy.start()
+	if fy.Pattern == CalendarMonths {
+		first := end
+		if start != time.January {
+			first--
+		}
+		return time.Date(first, start+time.Month(period-1), 1, 0, 0, 0, 0, loc)
+	}
+	for period > 12 {
+		end, period = end+1, period-12
+	}
+	for period < 1 {
+		end, period = end-1, period+12
+	}
+	weeks := fy.Pattern.weeks()
+	q := (period - 1) / 3
+	w := 13 * q
+	for i := 0; i < (period-1)%3; i++ {
+		w += weeks[i]
+	}
+	return time.Date(1970, 1, fy.lastDay(end-1)+1+7*w+1, 0, 0, 0, 0, loc)
+}
+
+// lastDay returns the day number of the last day of the fiscal year of
+// weeks ending in the calendar year end.
+func (fy *FiscalYear) lastDay(end int) int {
+	start := fy.start()
+	if start == time.January {
+		end++
+	}
+	// Day 0 of Start is the last day of the month before it.
+	n := dayNumber(end, start, 0)
+	diff := (int(fy.LastWeekday) - weekdayOf(n) + 7) % 7
+	if diff > 3 {
+		diff -= 7
+	}
+	return n + diff
+}
+
+// dayNumber returns the number of days from 1970-01-01 to the given date.
+func dayNumber(year int, month time.Month, day int) int {
+	return int(time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+}
+
+// weekdayOf returns the weekday of a day number.
+func weekdayOf(n int) int {
+	// 1970-01-01 was a Thursday.
+	return ((n+4)%7 + 7) % 7
+}
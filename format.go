@@ -0,0 +1,529 @@
+package timefmt
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Format is a compiled format string. It is created by Compile and can be
+// used both to format (Strftime) and to parse (Strptime) times without
+// re-scanning the format on every call.
+//
+// A Format is immutable and safe for concurrent use by multiple goroutines.
+type Format struct {
+	format  string
+	items   []formatItem
+	locale  *Locale
+	opts    ParseOptions
+	dialect *Dialect
+
+	// Parsing state. A format may be valid for Strftime but use directives
+	// Strptime does not support, so the error is kept until Parse is called.
+	scan       []scanItem
+	strictScan []scanItem
+	scanErr    error
+}
+
+// formatItem is either a literal run of the format or a single directive.
+type formatItem struct {
+	literal      string
+	spec         spec
+	cvt          func([]byte, time.Time, spec, *Locale) ([]byte, error)
+	offset, next int // the directive in the format, for errors
+}
+
+// spec is a directive together with its modifiers, following the GNU
+// grammar %[flags][width][E|O]code:
+//
+//	%-d  do not pad a numeric field
+//	%_d  pad a numeric field with spaces
+//	%0e  pad a numeric field with zeros
+//	%+Y  pad a numeric field with zeros and always give it a sign
+//	%^B  convert the result to upper case
+//	%#b  swap the case of the result
+//
+// The width is the minimum number of characters to output. E and O select
+// the locale's alternative representation: E the era of its Calendar for
+// %EC, %Ey, %EY, %Ex and %Ec, and Z for UTC in %Ez. They are accepted and
+// otherwise ignored, as in the C locale.
+// Up to three colons may precede z to choose the form of the UTC offset, and
+// one K to drop the century of the fiscal year.
+type spec struct {
+	code   rune // -1 if the format ended before the code
+	pad    byte // '-', '_', '0', '+' or 0 for the directive's default
+	width  int  // 0 for the directive's default
+	upper  bool
+	swap   bool
+	mod    byte // 'E', 'O' or 0
+	colons int
+	mode   formatMode
+}
+
+// formatMode says how a value is formatted beyond what its directives say.
+type formatMode uint8
+
+const (
+	// formatLeap formats the 59th second of a minute as the 60th, for a
+	// time in a leap second.
+	formatLeap formatMode = 1 << iota
+	// formatNaive formats a time without a zone, as Python does naive
+	// datetimes: %z and %Z are empty.
+	formatNaive
+	// formatNoDate and formatNoClock say the value has no date or no time
+	// of day, so the directives that need them fail.
+	formatNoDate
+	formatNoClock
+	// formatEraYear is set while expanding %EY, whose %Ey writes the first
+	// year of an era as the locale's FirstEraYear.
+	formatEraYear
+)
+
+// leapMode returns formatLeap if leap is set.
+func leapMode(leap bool) formatMode {
+	if leap {
+		return formatLeap
+	}
+	return 0
+}
+
+// Compile parses a format and returns a Format that can be used to format
+// and parse times. An error is returned if the format contains an unknown
+// directive.
+func Compile(format string) (*Format, error) {
+	return compile(format, English, &registered)
+}
+
+func compile(format string, l *Locale, d *Dialect) (*Format, error) {
+	items, e := scanFormat(format, d)
+	if nil != e {
+		return nil, e
+	}
+	f := &Format{format: format, items: items, locale: l, dialect: d}
+	f.compileParser()
+	return f, nil
+}
+
+// compileParser builds the scanners Parse matches with, which depend on the
+// names and patterns of f.locale.
+func (f *Format) compileParser() {
+	f.scan, f.scanErr = compileScan(f.format, f.locale, false, f.dialect)
+	f.strictScan = nil
+	if nil == f.scanErr {
+		// Both scanners have the same items in the same order.
+		f.strictScan, f.scanErr = compileScan(f.format, f.locale, true, f.dialect)
+	}
+}
+
+// MustCompile is like Compile but panics if the format cannot be compiled.
+// It simplifies safe initialization of global variables holding formats.
+func MustCompile(format string) *Format {
+	f, e := Compile(format)
+	if nil != e {
+		panic("timefmt: Compile(" + strconv.Quote(format) + "): " + e.Error())
+	}
+	return f
+}
+
+// String returns the source format used to compile f.
+func (f *Format) String() string {
+	return f.format
+}
+
+// WithLocale returns a copy of f that formats and parses using the names and
+// patterns of l. f itself is left unchanged.
+func (f *Format) WithLocale(l *Locale) *Format {
+	c := *f
+	c.locale = l
+	c.compileParser()
+	return &c
+}
+
+// Locale returns the locale f formats and parses with.
+func (f *Format) Locale() *Locale {
+	return f.locale
+}
+
+// Format returns t formatted according to f. Formatting stops at a
+// directive that fails, which only a malformed locale pattern makes a
+// built-in one do; Strftime reports the error.
+func (f *Format) Format(t time.Time) string {
+	var buf [64]byte
+	return string(f.AppendFormat(buf[:0], t))
+}
+
+// AppendFormat is like Format but appends the textual representation to b
+// and returns the extended buffer.
+func (f *Format) AppendFormat(b []byte, t time.Time) []byte {
+	b, _ = f.appendFormat(b, t, 0)
+	return b
+}
+
+// Strftime is like Format but returns the error of a directive that fails,
+// as a FormatError.
+func (f *Format) Strftime(t time.Time) (string, error) {
+	var buf [64]byte
+	b, e := f.AppendStrftime(buf[:0], t)
+	if e != nil {
+		return "", e
+	}
+	return string(b), nil
+}
+
+// AppendStrftime is like AppendFormat but returns the error of a directive
+// that fails, as a FormatError.
+func (f *Format) AppendStrftime(b []byte, t time.Time) ([]byte, error) {
+	return f.appendFormat(b, t, 0)
+}
+
+// FormatTAI is like Format but takes a time on the TAI time scale, as
+// StrftimeTAI does, so that a leap second is formatted as 23:59:60.
+func (f *Format) FormatTAI(tai time.Time) string {
+	var buf [64]byte
+	t, leap := FromTAI(tai)
+	b, _ := f.appendFormat(buf[:0], t, leapMode(leap))
+	return string(b)
+}
+
+func (f *Format) appendFormat(b []byte, t time.Time, mode formatMode) ([]byte, error) {
+	for i := range f.items {
+		it := &f.items[i]
+		if nil == it.cvt {
+			b = append(b, it.literal...)
+			continue
+		}
+		sp := it.spec
+		sp.mode = mode
+		var e error
+		if b, e = appendDirective(b, t, sp, it.cvt, f.locale); e != nil {
+			return b, directiveError(f.format, it.offset, it.next, e)
+		}
+	}
+	return b, nil
+}
+
+// directiveError returns e, the error of the directive at format offset
+// i ... next, as a FormatError unless it is one already, as the errors of
+// the locale pattern a directive expands are.
+func directiveError(format string, i, next int, e error) error {
+	if _, ok := e.(*FormatError); ok {
+		return e
+	}
+	return &FormatError{Format: format, Offset: i, Directive: format[i:next], Err: e}
+}
+
+// WithOptions returns a copy of f whose Parse completes times as opts says.
+// f itself is left unchanged.
+func (f *Format) WithOptions(opts ParseOptions) *Format {
+	c := *f
+	c.opts = opts
+	return &c
+}
+
+// Parse parses value according to f and returns the time it represents.
+func (f *Format) Parse(value string) (time.Time, error) {
+	return f.ParseWithOptions(value, f.opts)
+}
+
+// ParseWithOptions is like Parse but completes the time as opts says,
+// instead of as the options given to WithOptions.
+func (f *Format) ParseWithOptions(value string, opts ParseOptions) (time.Time, error) {
+	if nil != f.scanErr {
+		return time.Time{}, f.scanErr
+	}
+	dt := &_DateTime{}
+	dt.loc = time.UTC
+	sc := scanner{items: f.scan, value: value, strict: opts.Strict, fold: !opts.CaseSensitive, spaces: !opts.ExactSpace}
+	if opts.Strict {
+		sc.items = f.strictScan
+	}
+	var caps [32]int
+	if sc.caps = caps[:]; 2*len(sc.items) > len(caps) {
+		sc.caps = make([]int, 2*len(sc.items))
+	}
+	if e := f.match(&sc, dt); nil != e {
+		return time.Time{}, e
+	}
+	if !opts.Reference.IsZero() {
+		ref := opts.Reference
+		if nil != opts.Location {
+			ref = ref.In(opts.Location)
+		}
+		dt.fillFrom(ref)
+	}
+	if e := f.resolve(&sc, dt, opts); nil != e {
+		return time.Time{}, e
+	}
+	var leap bool
+	if dt.hasSec && dt.sec == 60 {
+		switch opts.LeapSecond {
+		case LeapSecondClamp:
+			dt.sec, dt.nsec = 59, 999999999
+		case LeapSecondReport:
+			leap = true
+		case LeapSecondReject:
+			return time.Time{}, f.blame(&sc, "S", ErrOutOfRange)
+		default:
+			if opts.Strict {
+				return time.Time{}, f.blame(&sc, "S", ErrOutOfRange)
+			}
+		}
+	}
+	if nil != opts.Location && dt.zone == 0 {
+		dt.loc = opts.Location
+	}
+
+	t := time.Date(dt.year, dt.month, dt.day, dt.hour, dt.min, dt.sec, dt.nsec, dt.loc)
+	if nil != opts.Location && dt.zone != 0 {
+		// The parsed zone wins, but like time.ParseInLocation the result
+		// stays in Location when that agrees with it.
+		name, offset := t.Zone()
+		lt := t.In(opts.Location)
+		lname, loffset := lt.Zone()
+		if dt.zone == 's' || (loffset == offset && (dt.zone != 'Z' || lname == name)) {
+			t = lt
+		}
+	}
+	if leap {
+		return t, f.blame(&sc, "S", ErrLeapSecond)
+	}
+	return t, nil
+}
+
+// ParseComponents parses value according to f like Parse, with the options
+// given to WithOptions, but returns the fields the value gave instead of a
+// time. ParseOptions.Reference and Location are not used, and a second of
+// 60 is kept.
+func (f *Format) ParseComponents(value string) (Components, error) {
+	if nil != f.scanErr {
+		return Components{}, f.scanErr
+	}
+	opts := f.opts
+	dt := &_DateTime{}
+	sc := scanner{items: f.scan, value: value, strict: opts.Strict, fold: !opts.CaseSensitive, spaces: !opts.ExactSpace}
+	if opts.Strict {
+		sc.items = f.strictScan
+	}
+	var caps [32]int
+	if sc.caps = caps[:]; 2*len(sc.items) > len(caps) {
+		sc.caps = make([]int, 2*len(sc.items))
+	}
+	if e := f.match(&sc, dt); nil != e {
+		return Components{}, e
+	}
+	if e := f.resolve(&sc, dt, opts); nil != e {
+		return Components{}, e
+	}
+	return dt.components(), nil
+}
+
+// match matches the value of sc and converts the text of each directive
+// into dt, checking that directives setting the same field agree.
+func (f *Format) match(sc *scanner, dt *_DateTime) error {
+	if !sc.find() {
+		return f.noMatch(sc)
+	}
+	var seen [128]bool // the classes of fields set so far
+	for k := range sc.items {
+		it := &sc.items[k]
+		if nil == it.cvt {
+			continue
+		}
+		groups := fieldGroups(it.group)
+		var before [6]int
+		for g := 0; g < len(groups); g++ {
+			before[g], _ = dt.field(groups[g])
+		}
+		val := sc.value[sc.caps[2*k]:sc.caps[2*k+1]]
+		if e := it.cvt(val, dt, f.locale); e != nil {
+			return f.itemError(sc, k, e)
+		}
+		for g := 0; g < len(groups); g++ {
+			after, class := dt.field(groups[g])
+			if class == 0 {
+				continue
+			}
+			if seen[class] && after != before[g] {
+				return f.itemError(sc, k, ErrInconsistent)
+			}
+			seen[class] = true
+		}
+	}
+	return nil
+}
+
+// resolve completes the fields of dt that depend on others: the year from
+// its century, era or calendar era, the date from a week date, day of the year, quarter
+// or fiscal period, and the hour from AM or PM. Strict parsing checks the
+// fields' ranges first.
+func (f *Format) resolve(sc *scanner, dt *_DateTime, opts ParseOptions) error {
+	if group := dt.resolveCentury(opts); group != 0 {
+		return f.blame(sc, string(group), ErrInconsistent)
+	}
+	if group, e := dt.resolveCalendar(f.locale.Calendar, opts.Strict); nil != e {
+		return f.blame(sc, string(group), e)
+	}
+	if group := dt.resolveEra(); group != 0 {
+		return f.blame(sc, string(group), ErrInconsistent)
+	}
+	if opts.Strict {
+		if group := dt.validate(); group != 0 {
+			return f.blame(sc, string(group), ErrOutOfRange)
+		}
+	}
+	if groups := dt.resolveDate(); groups != "" {
+		return f.blame(sc, groups, ErrInconsistent)
+	}
+	if groups := dt.resolvePeriods(&f.locale.FiscalYear); groups != "" {
+		return f.blame(sc, groups, ErrInconsistent)
+	}
+	if dt.hour12 && dt.hour == 12 {
+		dt.hour = 0 // 12 AM is midnight, 12 PM noon
+	}
+	if dt.hour12 && dt.pm && dt.hour < 12 {
+		dt.hour += 12
+	}
+	return nil
+}
+
+// itemError returns the ParseError for the text item k of a match got.
+func (f *Format) itemError(sc *scanner, k int, e error) error {
+	it := &sc.items[k]
+	start, end := sc.caps[2*k], sc.caps[2*k+1]
+	return &ParseError{
+		Format:       f.format,
+		Value:        sc.value,
+		FormatOffset: it.offset,
+		ValueOffset:  start,
+		Directive:    f.format[it.offset:it.next],
+		Expected:     it.expected(),
+		Text:         sc.value[start:end],
+		Err:          e,
+	}
+}
+
+// blame returns the ParseError for the last item of one of groups, which
+// set the field found to be wrong.
+func (f *Format) blame(sc *scanner, groups string, e error) error {
+	for k := len(sc.items) - 1; k >= 0; k-- {
+		if g := sc.items[k].group; g != 0 && strings.IndexByte(groups, g) >= 0 {
+			return f.itemError(sc, k, e)
+		}
+	}
+	return &ParseError{Format: f.format, Value: sc.value, Err: e}
+}
+
+// noMatch returns the ParseError for a value f does not match, blaming the
+// item that failed furthest into the value.
+func (f *Format) noMatch(sc *scanner) error {
+	err := &ParseError{Format: f.format, Value: sc.value, Err: ErrNoMatch}
+	err.FormatOffset, err.ValueOffset = len(f.format), sc.failAt
+	err.Text = sc.value[sc.failAt:]
+	if sc.failK < len(sc.items) {
+		// Otherwise a strict match failed on text after the format's end.
+		it := &sc.items[sc.failK]
+		err.FormatOffset, err.Directive, err.Expected = it.offset, f.format[it.offset:it.next], it.expected()
+	}
+	return err
+}
+
+// scanFormat splits format into literals and directives, resolving each
+// directive to its output converter, built in or registered in d.
+func scanFormat(format string, d *Dialect) ([]formatItem, error) {
+	var items []formatItem
+	lit := bytes.Buffer{}
+	flush := func() {
+		if lit.Len() > 0 {
+			items = append(items, formatItem{literal: lit.String()})
+			lit.Reset()
+		}
+	}
+	length := len(format)
+	for i := 0; i < length; {
+		c := format[i]
+		if c != 0x25 { // "%" -> 0x25
+			lit.WriteByte(c)
+			i++
+			continue
+		}
+		sp, next := cutDirective(format, i)
+		if sp.code < 0 {
+			lit.WriteString(incompleteDirective(format[i:next]))
+		} else if cvt_func, ok := ontput_converters[sp.code]; ok {
+			flush()
+			items = append(items, formatItem{spec: sp, cvt: cvt_func, offset: i, next: next})
+		} else if dir, ok := d.lookup(sp.code); ok {
+			flush()
+			items = append(items, formatItem{spec: sp, cvt: dir.output, offset: i, next: next})
+		} else {
+			return nil, &FormatError{Format: format, Offset: i, Directive: format[i:next], Err: ErrUnknownDirective}
+		}
+		i = next
+	}
+	flush()
+	return items, nil
+}
+
+// cutDirective parses the directive whose '%' is at format[i] and returns
+// its spec and the offset just past it. If the format ends before a code,
+// the spec's code is -1.
+func cutDirective(format string, i int) (s spec, next int) {
+	j := i + 1
+	for ; j < len(format); j++ {
+		switch c := format[j]; c {
+		case '-', '_', '0':
+			s.pad = c
+			continue
+		case '+':
+			// A flag only before a width or a letter, so that "%+"
+			// followed by anything else is still the date(1) format.
+			if j+1 < len(format) && isFlagged(format[j+1]) {
+				s.pad = c
+				continue
+			}
+		case '^':
+			s.upper = true
+			continue
+		case '#':
+			s.swap = true
+			continue
+		}
+		break
+	}
+	for ; j < len(format) && '0' <= format[j] && format[j] <= '9'; j++ {
+		s.width = s.width*10 + int(format[j]-'0')
+	}
+	if j < len(format) && (format[j] == 'E' || format[j] == 'O') {
+		s.mod = format[j]
+		j++
+	}
+	for ; j < len(format) && format[j] == ':' && s.colons < 3; j++ {
+		s.colons++
+	}
+	if j >= len(format) {
+		s.code = -1
+		return s, j
+	}
+	s.code = rune(format[j])
+	if s.colons > 0 && s.code != 'z' && (s.code != 'K' || s.colons > 1) {
+		// Only %z and %:K take colons; ':' names no directive, so the
+		// caller reports the whole directive as unknown.
+		s.code = ':'
+	}
+	return s, j + 1
+}
+
+// isFlagged reports whether c may follow the '+' flag.
+func isFlagged(c byte) bool {
+	return ('0' <= c && c <= '9') || ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z')
+}
+
+// incompleteDirective returns the literal output of a directive cut short by
+// the end of the format: a lone "%" is kept, otherwise the '%' is dropped.
+func incompleteDirective(d string) string {
+	if len(d) > 1 {
+		return d[1:]
+	}
+	return d
+}
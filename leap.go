@@ -0,0 +1,73 @@
+package timefmt
+
+import "time"
+
+// leapSeconds holds the months at whose end UTC inserted a leap second,
+// from the first in June 1972 to the last in December 2016, as published in
+// IERS Bulletin C. TAI was 10 seconds ahead of UTC before the first of them
+// and one more second ahead after each.
+var leapSeconds = []struct {
+	year  int
+	month time.Month
+}{
+	{1972, 6}, {1972, 12}, {1973, 12}, {1974, 12}, {1975, 12}, {1976, 12},
+	{1977, 12}, {1978, 12}, {1979, 12}, {1981, 6}, {1982, 6}, {1983, 6},
+	{1985, 6}, {1987, 12}, {1989, 12}, {1990, 12}, {1992, 6}, {1993, 6},
+	{1994, 6}, {1995, 12}, {1997, 6}, {1998, 12}, {2005, 12}, {2008, 12},
+	{2012, 6}, {2015, 6}, {2016, 12},
+}
+
+// leapSecondEnds holds, for each leap second, the Unix time of the midnight
+// that follows it.
+var leapSecondEnds = func() []int64 {
+	ends := make([]int64, len(leapSeconds))
+	for i, l := range leapSeconds {
+		ends[i] = time.Date(l.year, l.month+1, 1, 0, 0, 0, 0, time.UTC).Unix()
+	}
+	return ends
+}()
+
+// LeapSeconds returns the leap seconds known to the package, each as the
+// UTC time of the second before it (23:59:59 on its day), in order.
+func LeapSeconds() []time.Time {
+	times := make([]time.Time, len(leapSecondEnds))
+	for i, end := range leapSecondEnds {
+		times[i] = time.Unix(end-1, 0).UTC()
+	}
+	return times
+}
+
+// FromTAI converts a time on the TAI time scale to UTC. tai is read as a
+// clock that counts every second, leap seconds included: its Unix time is
+// seconds of TAI since 1970-01-01 00:00:00 TAI. If tai falls in a leap
+// second, FromTAI returns 23:59:59 with the fraction of the leap second and
+// true. The result is in tai's location. Before 1972, when UTC did not yet
+// step by whole seconds, TAI is taken to be 10 seconds ahead of UTC.
+func FromTAI(tai time.Time) (time.Time, bool) {
+	sec, nsec := tai.Unix(), int64(tai.Nanosecond())
+	offset := int64(10)
+	for _, end := range leapSecondEnds {
+		switch start := end + offset; {
+		case sec < start:
+			return time.Unix(sec-offset, nsec).In(tai.Location()), false
+		case sec == start:
+			return time.Unix(end-1, nsec).In(tai.Location()), true
+		}
+		offset++
+	}
+	return time.Unix(sec-offset, nsec).In(tai.Location()), false
+}
+
+// StrftimeTAI is like Strftime but takes a time on the TAI time scale, as
+// FromTAI does, so that a leap second is formatted as second 60 by %S, %T
+// and the other directives that include the seconds: 2016-12-31 23:59:60
+// in UTC, or 08:59:60 on the next day in Tokyo.
+func StrftimeTAI(tai time.Time, format string) (string, error) {
+	var buf [64]byte
+	t, leap := FromTAI(tai)
+	b, e := appendStrftime(buf[:0], t, format, English, leapMode(leap), &registered)
+	if e != nil {
+		return "", e
+	}
+	return string(b), nil
+}
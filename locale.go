@@ -0,0 +1,237 @@
+package timefmt
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Locale holds the names and preferred patterns used by the locale-dependent
+// directives: %a, %A, %b, %B, %h, %p, %P, %J, %c, %x, %X and %r, the
+// calendar of the directives with the E modifier and the fiscal year of %K,
+// %Q and %o.
+//
+// DateTimeFormat, DateFormat, TimeFormat and TimeFormat12 are Strftime formats
+// themselves and must not refer to %c, %x, %X or %r.
+type Locale struct {
+	// Name is the identifier the locale is registered under, such as "fr_FR".
+	Name string
+
+	ShortDayNames   [7]string  // %a, Sunday first
+	LongDayNames    [7]string  // %A, Sunday first
+	ShortMonthNames [12]string // %b, January first
+	LongMonthNames  [12]string // %B, January first
+
+	// AM and PM are the day periods used by %p.
+	AM, PM string
+
+	// Eras are the names of the eras before year 1 and from year 1 on, as
+	// used by %J. The first name of each is formatted and all are parsed.
+	// Locales without them use the English ones.
+	Eras [2][]string
+
+	DateTimeFormat string // %c
+	DateFormat     string // %x
+	TimeFormat     string // %X
+	TimeFormat12   string // %r
+
+	// Calendar is the calendar of %EC, %Ey, %EY, %Ex and %Ec, or nil for
+	// the Gregorian one. EraYearFormat, EraDateFormat and EraDateTimeFormat
+	// are the patterns of %EY, %Ex and %Ec in it; see Calendar. %EY writes
+	// the first year of an era as FirstEraYear if that is set, as in 令和元年,
+	// and %Ey parses it. EraYearFormat must not refer to %EY, %c, %x, %X
+	// or %r, and the other two not to %c, %x, %X or %r.
+	Calendar          Calendar
+	EraYearFormat     string // %EY, such as "%EC%Ey年"
+	FirstEraYear      string
+	EraDateFormat     string // %Ex
+	EraDateTimeFormat string // %Ec
+
+	// FiscalYear is the fiscal year of %K, %Q and %o. The bundled locales
+	// use the calendar year; copy one to set the fiscal year of a business.
+	FiscalYear FiscalYear
+}
+
+// English is the default locale, used by Strftime and Strptime.
+var English = &Locale{
+	Name:            "en_US",
+	ShortDayNames:   shortDayNames,
+	LongDayNames:    longDayNames,
+	ShortMonthNames: shortMonthNames,
+	LongMonthNames:  longMonthNames,
+	AM:              "AM",
+	PM:              "PM",
+	Eras:            [2][]string{{"BC", "BCE", "B.C."}, {"AD", "CE", "A.D."}},
+	DateTimeFormat:  "%a %b %d %H:%M:%S %Y",
+	DateFormat:      "%m/%d/%y",
+	TimeFormat:      "%H:%M:%S",
+	TimeFormat12:    "%I:%M:%S %p",
+}
+
+// French is the fr_FR locale.
+var French = &Locale{
+	Name:            "fr_FR",
+	ShortDayNames:   [7]string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+	LongDayNames:    [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+	ShortMonthNames: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+	LongMonthNames:  [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+	AM:              "AM",
+	PM:              "PM",
+	Eras:            [2][]string{{"av. J.-C."}, {"ap. J.-C."}},
+	DateTimeFormat:  "%a %d %b %Y %H:%M:%S",
+	DateFormat:      "%d/%m/%Y",
+	TimeFormat:      "%H:%M:%S",
+	TimeFormat12:    "%I:%M:%S %p",
+}
+
+// German is the de_DE locale.
+var German = &Locale{
+	Name:            "de_DE",
+	ShortDayNames:   [7]string{"So", "Mo", "Di", "Mi", "Do", "Fr", "Sa"},
+	LongDayNames:    [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+	ShortMonthNames: [12]string{"Jan", "Feb", "Mär", "Apr", "Mai", "Jun", "Jul", "Aug", "Sep", "Okt", "Nov", "Dez"},
+	LongMonthNames:  [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+	AM:              "AM",
+	PM:              "PM",
+	Eras:            [2][]string{{"v. Chr."}, {"n. Chr."}},
+	DateTimeFormat:  "%a %d %b %Y %H:%M:%S",
+	DateFormat:      "%d.%m.%Y",
+	TimeFormat:      "%H:%M:%S",
+	TimeFormat12:    "%I:%M:%S %p",
+}
+
+// Spanish is the es_ES locale.
+var Spanish = &Locale{
+	Name:            "es_ES",
+	ShortDayNames:   [7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+	LongDayNames:    [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+	ShortMonthNames: [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sep", "oct", "nov", "dic"},
+	LongMonthNames:  [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+	AM:              "a. m.",
+	PM:              "p. m.",
+	Eras:            [2][]string{{"a. C."}, {"d. C."}},
+	DateTimeFormat:  "%a %d %b %Y %H:%M:%S",
+	DateFormat:      "%d/%m/%y",
+	TimeFormat:      "%H:%M:%S",
+	TimeFormat12:    "%I:%M:%S %p",
+}
+
+// Japanese is the ja_JP locale.
+var Japanese = &Locale{
+	Name:            "ja_JP",
+	ShortDayNames:   [7]string{"日", "月", "火", "水", "木", "金", "土"},
+	LongDayNames:    [7]string{"日曜日", "月曜日", "火曜日", "水曜日", "木曜日", "金曜日", "土曜日"},
+	ShortMonthNames: [12]string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+	LongMonthNames:  [12]string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+	AM:              "午前",
+	PM:              "午後",
+	Eras:            [2][]string{{"紀元前"}, {"西暦"}},
+	DateTimeFormat:  "%Y年%m月%d日 %H時%M分%S秒",
+	DateFormat:      "%Y年%m月%d日",
+	TimeFormat:      "%H時%M分%S秒",
+	TimeFormat12:    "%p%I時%M分%S秒",
+
+	Calendar:          JapaneseCalendar,
+	EraYearFormat:     "%EC%Ey年",
+	FirstEraYear:      "元",
+	EraDateFormat:     "%EY%m月%d日",
+	EraDateTimeFormat: "%EY%m月%d日 %H時%M分%S秒",
+}
+
+// Chinese is the zh_CN locale.
+var Chinese = &Locale{
+	Name:            "zh_CN",
+	ShortDayNames:   [7]string{"日", "一", "二", "三", "四", "五", "六"},
+	LongDayNames:    [7]string{"星期日", "星期一", "星期二", "星期三", "星期四", "星期五", "星期六"},
+	ShortMonthNames: [12]string{"1月", "2月", "3月", "4月", "5月", "6月", "7月", "8月", "9月", "10月", "11月", "12月"},
+	LongMonthNames:  [12]string{"一月", "二月", "三月", "四月", "五月", "六月", "七月", "八月", "九月", "十月", "十一月", "十二月"},
+	AM:              "上午",
+	PM:              "下午",
+	Eras:            [2][]string{{"公元前"}, {"公元"}},
+	DateTimeFormat:  "%Y年%m月%d日 %A %H时%M分%S秒",
+	DateFormat:      "%Y年%m月%d日",
+	TimeFormat:      "%H时%M分%S秒",
+	TimeFormat12:    "%p %I时%M分%S秒",
+}
+
+var locales = struct {
+	sync.RWMutex
+	byName map[string]*Locale
+}{byName: map[string]*Locale{}}
+
+func init() {
+	for _, l := range []*Locale{English, French, German, Spanish, Japanese, Chinese} {
+		RegisterLocale(l)
+	}
+}
+
+// RegisterLocale makes l available to LookupLocale under l.Name, replacing
+// any locale previously registered under the same name. It is safe to call
+// from multiple goroutines.
+func RegisterLocale(l *Locale) {
+	locales.Lock()
+	locales.byName[canonicalLocaleName(l.Name)] = l
+	locales.Unlock()
+}
+
+// LookupLocale returns the registered locale with the given name. Names are
+// matched loosely: "fr-FR", "fr_FR.UTF-8" and "fr_fr" all find fr_FR, and a
+// bare language such as "fr" finds the first registered locale (by name) for
+// that language.
+func LookupLocale(name string) (*Locale, bool) {
+	name = canonicalLocaleName(name)
+	locales.RLock()
+	defer locales.RUnlock()
+	if l, ok := locales.byName[name]; ok {
+		return l, true
+	}
+	if strings.IndexByte(name, '_') < 0 {
+		var found string
+		for n := range locales.byName {
+			if strings.HasPrefix(n, name+"_") && (found == "" || n < found) {
+				found = n
+			}
+		}
+		if found != "" {
+			return locales.byName[found], true
+		}
+	}
+	return nil, false
+}
+
+// Locales returns the names of all registered locales in sorted order.
+func Locales() []string {
+	locales.RLock()
+	names := make([]string, 0, len(locales.byName))
+	for _, l := range locales.byName {
+		names = append(names, l.Name)
+	}
+	locales.RUnlock()
+	sort.Strings(names)
+	return names
+}
+
+// eraNames returns the names of the era before year 1 if bc is set, or else
+// of the era from year 1 on.
+func eraNames(l *Locale, bc bool) []string {
+	i := 1
+	if bc {
+		i = 0
+	}
+	if len(l.Eras[i]) == 0 {
+		return English.Eras[i]
+	}
+	return l.Eras[i]
+}
+
+// canonicalLocaleName turns "fr-fr.UTF-8" into "fr_FR".
+func canonicalLocaleName(name string) string {
+	if i := strings.IndexAny(name, ".@"); i >= 0 {
+		name = name[:i]
+	}
+	name = strings.Replace(name, "-", "_", -1)
+	if i := strings.IndexByte(name, '_'); i >= 0 {
+		return strings.ToLower(name[:i]) + "_" + strings.ToUpper(name[i+1:])
+	}
+	return strings.ToLower(name)
+}
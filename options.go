@@ -0,0 +1,118 @@
+package timefmt
+
+import "time"
+
+// ParseOptions controls how StrptimeWithOptions and Format.ParseWithOptions
+// complete a time from the fields the format provides. The zero value parses
+// exactly like Strptime.
+type ParseOptions struct {
+	// Location is the zone of a value that names none with %z, %Z or %s.
+	// nil means UTC.
+	//
+	// When the value does name a zone, the parsed zone wins. As with
+	// time.ParseInLocation, the result is then still returned in Location
+	// if Location uses the same offset at that instant (and, for %Z, the
+	// same abbreviation); otherwise it is returned in the parsed zone. A %s
+	// timestamp is always returned in Location.
+	Location *time.Location
+
+	// Reference supplies the fields the format omits, like the default of
+	// Python's dateutil. Its date and time are read in Location if one is
+	// given. Year, month and day come from Reference, with the day clamped
+	// to the end of a parsed month. Omitted time-of-day fields larger than
+	// the smallest one parsed come from Reference and smaller ones are zero,
+	// so "%H:%M" gives zero seconds on Reference's date, and a format
+	// without any time-of-day field takes Reference's clock.
+	//
+	// The zero time leaves omitted fields zero, as Strptime does.
+	Reference time.Time
+
+	// Century resolves two-digit years (%y and %g) that no %C accompanies.
+	// nil means PivotYear(1970), which puts 70-99 in the 1900s and 00-69 in
+	// the 2000s.
+	Century CenturyRule
+
+	// Strict makes parsing fail rather than guess:
+	//
+	//   - the format must match the whole value, not just part of it;
+	//   - numbers must have the directive's width, zero-padded (%d is "05")
+	//     or space-padded (%e is " 5") as the directive and its flags say,
+	//     unless the '-' flag allows fewer digits; %3f, %N and %L take
+	//     exactly 3, 9 and 3 digits;
+	//   - months, days (leap years included), hours, minutes, seconds and
+	//     week numbers out of range are errors instead of being normalised
+	//     by time.Date, so "2016-02-30" does not become March 1st.
+	Strict bool
+
+	// Like Python's strptime, parsing ignores case in names and literals,
+	// so %b matches "SEP" and "sep" and %p matches "pm", and a run of white
+	// space in the format matches any run of at least one white space
+	// character in the value. CaseSensitive and ExactSpace turn these off.
+	CaseSensitive bool
+	ExactSpace    bool
+
+	// LeapSecond says what a second of 60 (23:59:60 in a leap second)
+	// becomes, since time.Time cannot hold one. The zero value rolls it
+	// over to the next minute like time.Date does, except that Strict then
+	// rejects it.
+	LeapSecond LeapSecondPolicy
+}
+
+// A LeapSecondPolicy says how parsing treats a second of 60.
+type LeapSecondPolicy int
+
+const (
+	// LeapSecondRollOver rolls a second of 60 over to the next minute, so
+	// 23:59:60 is 00:00:00 of the next day.
+	LeapSecondRollOver LeapSecondPolicy = iota
+	// LeapSecondReject fails with ErrOutOfRange, as for second 61.
+	LeapSecondReject
+	// LeapSecondClamp returns the last instant of second 59 instead,
+	// 23:59:59.999999999, keeping the time in the minute of the value.
+	LeapSecondClamp
+	// LeapSecondReport rolls the second over but also returns a ParseError
+	// wrapping ErrLeapSecond, like strconv.ParseFloat returns a value with
+	// ErrRange. The time is valid when errors.Is(err, ErrLeapSecond).
+	LeapSecondReport
+)
+
+// A CenturyRule returns the full year a two-digit year yy (0-99) stands for.
+// ref is ParseOptions.Reference, or the current time if that is zero.
+type CenturyRule func(yy int, ref time.Time) int
+
+// PivotYear returns a rule that puts two-digit years in the hundred years
+// starting at start: with PivotYear(1950), 50 is 1950 and 49 is 2049.
+func PivotYear(start int) CenturyRule {
+	return func(yy int, ref time.Time) int {
+		return windowYear(yy, start)
+	}
+}
+
+// SlidingWindow returns a rule that puts two-digit years in the hundred years
+// starting past years before the reference year: with SlidingWindow(50) and
+// a reference in 2016, 66 is 1966 and 65 is 2065.
+func SlidingWindow(past int) CenturyRule {
+	return func(yy int, ref time.Time) int {
+		return windowYear(yy, ref.Year()-past)
+	}
+}
+
+// PreferPast resolves a two-digit year to the latest year not after the
+// reference year, as fits birth dates.
+var PreferPast = SlidingWindow(99)
+
+// PreferFuture resolves a two-digit year to the earliest year not before the
+// reference year, as fits expiry dates.
+var PreferFuture = SlidingWindow(0)
+
+// defaultCentury is the rule Strptime has always used.
+var defaultCentury = PivotYear(1970)
+
+// windowYear returns the year ending in yy among start ... start+99.
+func windowYear(yy int, start int) int {
+	y := start - (start%100+100)%100 + yy
+	if y < start {
+		y += 100
+	}
+	return y
+}
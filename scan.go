@@ -0,0 +1,519 @@
+package timefmt
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// scanItem is one step of the scanner Parse matches a value with: a literal,
+// a number, one of a list of names or some other text.
+type scanItem struct {
+	kind    byte   // scanLiteral, scanSpaces, scanNumber, scanNames or scanText
+	literal string // scanLiteral and scanSpaces
+	group   byte   // the input_converters entry the text goes to, 0 for none
+	cvt     func(string, *_DateTime, *Locale) error
+
+	// scanNumber: blanks is the number of blanks allowed before the digits
+	// (-1 for any), total the exact width of blanks and digits (0 for any)
+	// and prefer the number of digits tried first (0 for the most).
+	min, max      int
+	blanks, total int
+	prefer        int
+	sign, signed  bool // a sign is allowed, or required
+	minus         bool // a minus sign is allowed, before as few as one digit
+
+	names   []string           // scanNames, longest first
+	scan    func(s string) int // scanText
+	pattern string             // scanText
+
+	// The directive the item comes from, for errors. Items expanded from
+	// %c or %D share the offsets of that directive.
+	offset, next int
+}
+
+const (
+	scanLiteral = iota
+	scanSpaces
+	scanNumber
+	scanNames
+	scanText
+)
+
+// compileScan turns format into the items of a scanner. Strict numbers must
+// have the width of their directive. Directives not built in are looked up
+// in d.
+func compileScan(format string, l *Locale, strict bool, d *Dialect) ([]scanItem, error) {
+	return appendScan(nil, format, l, strict, -1, -1, false, d)
+}
+
+// appendScan appends the items of format to items. Items expanded from the
+// composite directive at format offset ... next share those offsets; offset
+// is -1 at the top level. inLocale is set while expanding a locale's %c, %x,
+// %X or %r pattern, or the era pattern of %EY, %Ex or %Ec, which may not
+// nest. The patterns of the locale and of the composite directives use only
+// built-in directives, so d is nil there.
+func appendScan(items []scanItem, format string, l *Locale, strict bool, offset, next int, inLocale bool, d *Dialect) ([]scanItem, error) {
+	// Literals are split into runs of white space and runs of other text.
+	var literal = func(s string, i, j int) {
+		for s != "" {
+			kind, n := byte(scanSpaces), scanSpace(s)
+			if n == 0 {
+				kind, n = scanLiteral, strings.IndexAny(s, spaces)
+				if n < 0 {
+					n = len(s)
+				}
+			}
+			if last := len(items) - 1; last >= 0 && items[last].kind == kind && items[last].next == i {
+				items[last].literal += s[:n]
+				items[last].next = j
+			} else {
+				items = append(items, scanItem{kind: kind, literal: s[:n], offset: i, next: j})
+			}
+			s = s[n:]
+		}
+	}
+	length := len(format)
+	for i := 0; i < length; {
+		at, to := offset, next
+		c := format[i]
+		if c != 0x25 { // "%" -> 0x25
+			if offset < 0 {
+				at, to = i, i+1
+			}
+			literal(format[i:i+1], at, to)
+			i++
+			continue
+		}
+		sp, j := cutDirective(format, i)
+		if offset < 0 {
+			at, to = i, j
+		}
+		code := sp.code
+		if code < 0 {
+			literal(incompleteDirective(format[i:j]), at, to)
+		} else if code == '%' {
+			literal("%", at, to)
+		} else if inLocale && (code == 'c' || code == 'x' || code == 'X' || code == 'r') {
+			return nil, errors.New("Recursive locale pattern:" + format)
+		} else if pattern, ok, e := eraPattern(code, l); sp.mod == 'E' && (ok || nil != e) {
+			if nil != e {
+				return nil, e
+			}
+			if items, e = appendScan(items, pattern, l, strict, at, to, true, nil); nil != e {
+				return nil, e
+			}
+		} else if pattern, ok := localePattern(code, l); ok {
+			var e error
+			if items, e = appendScan(items, pattern, l, strict, at, to, true, nil); nil != e {
+				return nil, e
+			}
+		} else if composite, ok := composite_formats[code]; ok {
+			var e error
+			if items, e = appendScan(items, composite, l, strict, at, to, inLocale, nil); nil != e {
+				return nil, e
+			}
+		} else {
+			var it scanItem
+			if sp.mod == 'E' && nil != l.Calendar && (code == 'C' || code == 'y') {
+				it = calendarItem(code, l, at, to)
+			} else if names, group, ok := localeNames(code, l); ok {
+				it = scanItem{kind: scanNames, group: group, names: names, offset: at, next: to}
+			} else if field, ok := input_fields[code]; ok {
+				if code == 'K' && sp.colons > 0 {
+					field = shortFiscalYear
+				}
+				it = fieldItem(field, sp, strict, at, to)
+			} else if dir, ok := d.lookup(code); ok && nil != dir.re {
+				it = scanItem{kind: scanText, cvt: dir.input, scan: dir.scan, pattern: dir.ParsePattern, offset: at, next: to}
+			} else {
+				err := ErrUnknownDirective
+				if _, ok := ontput_converters[code]; ok || nil != dir {
+					err = ErrUnsupportedDirective
+				}
+				return nil, &FormatError{Format: format, Offset: i, Directive: format[i:j], Err: err}
+			}
+			if it.group != 0 {
+				if it.cvt = input_converters[rune(it.group)]; nil == it.cvt {
+					return nil, &FormatError{Format: format, Offset: i, Directive: format[i:j], Err: ErrUnsupportedDirective}
+				}
+			}
+			items = append(items, it)
+		}
+		i = j
+	}
+	return items, nil
+}
+
+// localePattern returns the pattern of l that %c, %x, %X or %r stand for.
+func localePattern(code rune, l *Locale) (string, bool) {
+	switch code {
+	//| %c	| Locale’s appropriate date and time representation.	| Mon Sep 30 07:06:05 2013|
+	case 'c':
+		return l.DateTimeFormat, true
+	//| %x	| Locale’s appropriate date representation.	| 09/30/13|
+	case 'x':
+		return l.DateFormat, true
+	//| %X	| Locale’s appropriate time representation.	| 07:06:05|
+	case 'X':
+		return l.TimeFormat, true
+	//| %r	| Locale’s 12-hour clock time.	| 07:06:05 AM|
+	case 'r':
+		return l.TimeFormat12, true
+	}
+	return "", false
+}
+
+// calendarItem returns the item of %EC or %Ey for the calendar of l: any
+// name of an era, or the year of an era in digits or as FirstEraYear.
+func calendarItem(code rune, l *Locale, offset, next int) scanItem {
+	if code == 'C' {
+		var names []string
+		for era := 0; ; era++ {
+			eraNames := l.Calendar.EraNames(era)
+			if nil == eraNames {
+				break
+			}
+			names = append(names, eraNames...)
+		}
+		sort.SliceStable(names, func(i, j int) bool {
+			return len(names[i]) > len(names[j])
+		})
+		return scanItem{kind: scanNames, group: 'E', names: names, offset: offset, next: next}
+	}
+	first, pattern := l.FirstEraYear, "[0-9]+"
+	if first != "" {
+		pattern += "|" + regexp.QuoteMeta(first)
+	}
+	scan := func(s string) int {
+		if n := scanDigits(s, len(s)); n > 0 {
+			return n
+		}
+		if first != "" && strings.HasPrefix(s, first) {
+			return len(first)
+		}
+		return -1
+	}
+	return scanItem{kind: scanText, group: 'e', scan: scan, pattern: pattern, offset: offset, next: next}
+}
+
+// fieldItem returns the item of a directive described by field, applying
+// the directive's flags and, when strict, its width.
+func fieldItem(field inputField, sp spec, strict bool, offset, next int) scanItem {
+	it := scanItem{kind: scanNumber, group: field.group, min: field.min, max: field.max, sign: field.sign, minus: field.minus, offset: offset, next: next}
+	if nil != field.scan {
+		it.kind, it.scan, it.pattern = scanText, field.scan, field.pattern
+		return it
+	}
+	if field.max < 0 {
+		// A year has four digits unless the value needs more.
+		it.prefer = field.width
+	}
+	if sp.pad == '+' {
+		it.sign = true
+	}
+	if field.pad == ' ' {
+		it.blanks = 1
+	}
+	if sp.pad == '_' {
+		it.blanks = -1
+	}
+	if !strict || field.width == 0 {
+		return it
+	}
+	width, pad := field.width, field.pad
+	if sp.width > 0 {
+		width = sp.width
+	}
+	if sp.pad != 0 && pad != 0 {
+		pad = sp.pad
+	}
+	switch pad {
+	case '-':
+		it.blanks, it.min, it.max = 0, 1, width
+	case '_', ' ':
+		it.blanks, it.min, it.max, it.total = width-1, 1, width, width
+	case '+':
+		// The width counts the sign, which is then required.
+		if sp.width > 0 {
+			width = sp.width - 1
+		}
+		it.blanks, it.min, it.max, it.signed = 0, width, width, true
+	default:
+		it.blanks, it.min, it.max = 0, width, width
+	}
+	if field.max < 0 {
+		it.max = -1
+	}
+	return it
+}
+
+// expected returns the text it accepts in the notation of regexp, for
+// errors.
+func (it *scanItem) expected() string {
+	switch it.kind {
+	case scanNames:
+		return strings.Join(it.names, "|")
+	case scanText:
+		return it.pattern
+	case scanNumber:
+		s := ""
+		switch {
+		case it.blanks < 0:
+			s = " *"
+		case it.total > 0:
+			s = " {0," + strconv.Itoa(it.blanks) + "}"
+		case it.blanks > 0:
+			s = " ?"
+		}
+		if it.signed {
+			s += "[+-]"
+		} else if it.sign {
+			s += "[+-]?"
+		} else if it.minus {
+			s += "-?"
+		}
+		switch {
+		case it.max < 0 && it.min > 1:
+			return s + "[0-9]{" + strconv.Itoa(it.min) + ",}"
+		case it.max < 0:
+			return s + "[0-9]+"
+		case it.min == it.max:
+			return s + "[0-9]{" + strconv.Itoa(it.min) + "}"
+		}
+		return s + "[0-9]{" + strconv.Itoa(it.min) + "," + strconv.Itoa(it.max) + "}"
+	}
+	return it.literal
+}
+
+// scanner matches a value against scan items. Numbers backtrack over the
+// number of digits they take, so that one gives back the digits a following
+// directive needs as a regexp would, and names over the names that match,
+// longest first. Other text is never given back.
+type scanner struct {
+	items  []scanItem
+	value  string
+	strict bool  // the items must match the whole value
+	fold   bool  // letters match in either case
+	spaces bool  // white space in literals matches any run of white space
+	caps   []int // start and end in value of the text of each item
+	start  int   // where in value the match being tried starts
+
+	// The item that failed furthest from the start of its match, where
+	// in value it failed and where that match started.
+	failK, failAt, failStart int
+
+	// failed has the bit k*(len(value)+1)+i set once items[k:] failed to
+	// match from i on, which they then always do, so that backtracking
+	// over adjacent numbers does not try the same split twice.
+	failed []uint64
+}
+
+// find matches the value from its start when strict, or else from the first
+// position where the items match, like regexp.FindStringSubmatchIndex.
+func (s *scanner) find() bool {
+	s.failK, s.failAt = -1, -1
+	var failed [16]uint64
+	s.failed = failed[:]
+	if n := ((len(s.items)+1)*(len(s.value)+1) + 63) / 64; n > len(failed) {
+		s.failed = make([]uint64, n)
+	}
+	if s.strict {
+		return s.match(0, 0)
+	}
+	for s.start = 0; s.start <= len(s.value); s.start++ {
+		if s.match(0, s.start) {
+			return true
+		}
+	}
+	return false
+}
+
+// match reports whether items[k:] match the value from i on. A failure
+// already seen is not tried again: the failures it records, from an earlier
+// start, are further from theirs than they would be from this one.
+func (s *scanner) match(k int, i int) bool {
+	bit := k*(len(s.value)+1) + i
+	if s.failed[bit/64]&(1<<uint(bit%64)) != 0 {
+		return false
+	}
+	if s.try(k, i) {
+		return true
+	}
+	s.failed[bit/64] |= 1 << uint(bit%64)
+	return false
+}
+
+// try matches items[k] at i and the items after it from where it ends.
+func (s *scanner) try(k int, i int) bool {
+	if k == len(s.items) {
+		if s.strict && i != len(s.value) {
+			s.fail(k, i)
+			return false
+		}
+		return true
+	}
+	it := &s.items[k]
+	v := s.value[i:]
+	switch it.kind {
+	case scanLiteral:
+		if s.hasPrefix(v, it.literal) {
+			return s.capture(k, i, i+len(it.literal))
+		}
+	case scanSpaces:
+		if !s.spaces {
+			if strings.HasPrefix(v, it.literal) {
+				return s.capture(k, i, i+len(it.literal))
+			}
+			break
+		}
+		for n := scanSpace(v); n > 0; n-- {
+			if s.capture(k, i, i+n) {
+				return true
+			}
+		}
+	case scanNames:
+		for _, name := range it.names {
+			if name != "" && s.hasPrefix(v, name) && s.capture(k, i, i+len(name)) {
+				return true
+			}
+		}
+	case scanText:
+		if n := it.scan(v); n >= 0 {
+			return s.capture(k, i, i+n)
+		}
+	case scanNumber:
+		j := 0
+		for j < len(v) && v[j] == ' ' && (it.blanks < 0 || j < it.blanks) {
+			j++
+		}
+		start, min := j, it.min
+		if it.sign && j < len(v) && (v[j] == '-' || v[j] == '+') {
+			j++
+		} else if it.signed {
+			break
+		} else if it.minus && j < len(v) && v[j] == '-' {
+			// As %Y writes -44 for the year -44.
+			j, min = j+1, 1
+		}
+		n := 0
+		for j+n < len(v) && '0' <= v[j+n] && v[j+n] <= '9' && (it.max < 0 || n < it.max) {
+			n++
+		}
+		if it.prefer > 0 && it.prefer <= n && s.capture(k, i+start, i+j+it.prefer) {
+			return true
+		}
+		for ; n >= min && n > 0; n-- {
+			if (it.total > 0 && j+n != it.total) || n == it.prefer {
+				continue
+			}
+			if s.capture(k, i+start, i+j+n) {
+				return true
+			}
+		}
+	}
+	s.fail(k, i)
+	return false
+}
+
+// hasPrefix reports whether v begins with prefix, in either case if folding.
+func (s *scanner) hasPrefix(v string, prefix string) bool {
+	if !s.fold {
+		return strings.HasPrefix(v, prefix)
+	}
+	return len(v) >= len(prefix) && strings.EqualFold(v[:len(prefix)], prefix)
+}
+
+// capture records the text of items[k] and matches the rest from end on.
+func (s *scanner) capture(k int, start, end int) bool {
+	s.caps[2*k], s.caps[2*k+1] = start, end
+	return s.match(k+1, end)
+}
+
+// fail records that items[k] did not match at i, keeping the failure that
+// got furthest from the start of its match for errors.
+func (s *scanner) fail(k int, i int) {
+	if s.failK < 0 || i-s.start > s.failAt-s.failStart {
+		s.failK, s.failAt, s.failStart = k, i, s.start
+	}
+}
+
+// scanOffset matches the UTC offsets of %z: Z, GMT or UTC optionally
+// followed by [+-]h, [+-]hh, [+-]hhmm..., or [+-]hh[[:]mm[[:]ss]]. An offset
+// followed by more digits than these take is no offset.
+func scanOffset(s string) int {
+	if strings.HasPrefix(s, "Z") {
+		return 1
+	}
+	n, min := 0, 2
+	if strings.HasPrefix(s, "GMT") || strings.HasPrefix(s, "UTC") {
+		n, min = 3, 1
+	}
+	if n < len(s) && (s[n] == '+' || s[n] == '-') {
+		if d := scanDigits(s[n+1:], 2); d >= min {
+			n += 1 + d
+			for k := 0; k < 2; k++ {
+				j := n
+				if j < len(s) && s[j] == ':' {
+					j++
+				}
+				if scanDigits(s[j:], 2) != 2 {
+					break
+				}
+				n = j + 2
+			}
+			if scanDigits(s[n:], 1) > 0 {
+				// Digits left over, as in +12345, make no offset.
+				return -1
+			}
+			return n
+		}
+	}
+	if n == 3 {
+		return n
+	}
+	return -1
+}
+
+// scanZoneName matches the names of %Z: a letter followed by at least one
+// letter, digit or one of "/_+-".
+func scanZoneName(s string) int {
+	n := 0
+	for ; n < len(s); n++ {
+		c := s[n]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') {
+			continue
+		}
+		if n > 0 && (('0' <= c && c <= '9') || c == '/' || c == '_' || c == '+' || c == '-') {
+			continue
+		}
+		break
+	}
+	if n < 2 {
+		return -1
+	}
+	return n
+}
+
+// spaces are the white space characters, as in regexp's \s.
+const spaces = " \t\n\f\r"
+
+// scanSpace matches any run of white space, as %n and %t do.
+func scanSpace(s string) int {
+	n := 0
+	for n < len(s) && strings.IndexByte(spaces, s[n]) >= 0 {
+		n++
+	}
+	return n
+}
+
+// scanDigits returns the number of digits, at most max, at the start of s.
+func scanDigits(s string, max int) int {
+	n := 0
+	for n < len(s) && n < max && '0' <= s[n] && s[n] <= '9' {
+		n++
+	}
+	return n
+}
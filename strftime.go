@@ -2,195 +2,471 @@ package timefmt
 
 import (
     "time"
-    "fmt"
-    "bytes"
     "errors"
+    "io"
+    "unicode"
+    "unicode/utf8"
 )
 
 //| %a	| Weekday as locale’s abbreviated name.	| Mon|
-func cvt_output_a(t time.Time, flags bool) (string, error) {
-    return shortDayNames[t.Weekday()], nil
+func cvt_output_a(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return append(b, l.ShortDayNames[t.Weekday()]...), nil
 }
 
 //| %A	| Weekday as locale’s full name.	| Monday|
-func cvt_output_A(t time.Time, flags bool) (string, error) {
-    return longDayNames[t.Weekday()], nil
+func cvt_output_A(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return append(b, l.LongDayNames[t.Weekday()]...), nil
 }
 
 //| %w	| Weekday as a decimal number, where 0 is Sunday and 6 is Saturday.	| 1|
-func cvt_output_w(t time.Time, flags bool) (string, error) {
-    return fmt.Sprintf("%d", t.Weekday()), nil
+func cvt_output_w(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendNumber(b, int(t.Weekday()), s, 1, '0'), nil
 }
 
 //| %d	| Day of the month as a zero-padded decimal number.	| 30|
 //| %-d	| Day of the month as a decimal number. (Platform specific)	| 30|
-func cvt_output_d(t time.Time, flags bool) (string, error) {
-    if flags {
-        return fmt.Sprintf("%d", t.Day()), nil
-    } else {
-        return fmt.Sprintf("%02d", t.Day()), nil
-    }
+func cvt_output_d(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendNumber(b, t.Day(), s, 2, '0'), nil
 }
 
 //| %b	| Month as locale’s abbreviated name.	| Sep|
-func cvt_output_b(t time.Time, flags bool) (string, error) {
-    return shortMonthNames[t.Month()], nil
+func cvt_output_b(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return append(b, l.ShortMonthNames[t.Month()-1]...), nil
 }
 
 //| %B	| Month as locale’s full name.	| September|
-func cvt_output_B(t time.Time, flags bool) (string, error) {
-    return longMonthNames[t.Month()], nil
+func cvt_output_B(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return append(b, l.LongMonthNames[t.Month()-1]...), nil
 }
 
 //| %m	| Month as a zero-padded decimal number.	| 09|
 //| %-m	| Month as a decimal number. (Platform specific)	| 9|
-func cvt_output_m(t time.Time, flags bool) (string, error) {
-    if flags {
-        return fmt.Sprintf("%d", t.Month()), nil
-    } else {
-        return fmt.Sprintf("%02d", t.Month()), nil
-    }
+func cvt_output_m(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendNumber(b, int(t.Month()), s, 2, '0'), nil
 }
 
 //| %y	| Year without century as a zero-padded decimal number.	| 13|
-func cvt_output_y(t time.Time, flags bool) (string, error) {
-    return fmt.Sprintf("%02d", t.Year()%100), nil
+//| %Ey	| Year of the era of the locale’s calendar as a decimal number.	| 8|
+func cvt_output_y(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    if d, ok := calendarDate(t, s, l); ok {
+        if d.Year == 1 && s.mode&formatEraYear != 0 && l.FirstEraYear != "" {
+            return append(b, l.FirstEraYear...), nil
+        }
+        return appendNumber(b, d.Year, s, 1, '0'), nil
+    }
+    // Years before 1 count down from the century, as in glibc: -44 is 56.
+    return appendNumber(b, (t.Year()%100+100)%100, s, 2, '0'), nil
 }
 
 //| %Y	| Year with century as a decimal number.	| 2013|
-func cvt_output_Y(t time.Time, flags bool) (string, error) {
-    return fmt.Sprintf("%d", t.Year()), nil
+//| %+Y	| Year with a sign and at least four digits, as ISO 8601 expanded years. A width counts the sign.	| +2013|
+//| %EY	| Year in the locale’s calendar, with the name of the era.	| 令和8年|
+func cvt_output_Y(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    if _, ok := calendarDate(t, s, l); ok {
+        pattern, _, e := eraPattern('Y', l)
+        if e != nil {
+            return b, e
+        }
+        return appendStrftime(b, t, pattern, l, s.mode|formatEraYear, nil)
+    }
+    if s.pad == '+' {
+        return appendNumber(b, t.Year(), s, 4, '0'), nil
+    }
+    return appendNumber(b, t.Year(), s, 1, '0'), nil
 }
 
 //| %H	| Hour (24-hour clock) as a zero-padded decimal number.	| 07|
 //| %-H	| Hour (24-hour clock) as a decimal number. (Platform specific)	| 7|
-func cvt_output_H(t time.Time, flags bool) (string, error) {
-    if flags {
-        return fmt.Sprintf("%d", t.Hour()), nil
-    } else {
-        return fmt.Sprintf("%02d", t.Hour()), nil
-    }
+func cvt_output_H(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendNumber(b, t.Hour(), s, 2, '0'), nil
 }
 
 //| %I	| Hour (12-hour clock) as a zero-padded decimal number.	| 07|
 //| %-I	| Hour (12-hour clock) as a decimal number. (Platform specific)	| 7|
-func cvt_output_I(t time.Time, flags bool) (string, error) {
-    if flags {
-        return fmt.Sprintf("%d", t.Hour()%12), nil
-    } else {
-        return fmt.Sprintf("%02d", t.Hour()%12), nil
+func cvt_output_I(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendNumber(b, hour12(t), s, 2, '0'), nil
+}
+
+// hour12 returns the hour on the 12-hour clock, where noon and midnight are
+// 12 rather than 0.
+func hour12(t time.Time) int {
+    if h := t.Hour() % 12; h != 0 {
+        return h
     }
+    return 12
 }
 
 //| %p	| Locale’s equivalent of either AM or PM.	| AM|
-func cvt_output_p(t time.Time, flags bool) (string, error) {
-    if t.Hour() > 12 {
-        return "PM", nil
+func cvt_output_p(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    if t.Hour() >= 12 {
+        return append(b, l.PM...), nil
     } else {
-        return "AM", nil
+        return append(b, l.AM...), nil
     }
 }
 
 //| %M	| Minute as a zero-padded decimal number.	| 06|
 //| %-M	| Minute as a decimal number. (Platform specific)	| 6|
-func cvt_output_M(t time.Time, flags bool) (string, error) {
-    if flags {
-        return fmt.Sprintf("%d", t.Minute()), nil
-    } else {
-        return fmt.Sprintf("%02d", t.Minute()), nil
-    }
+func cvt_output_M(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendNumber(b, t.Minute(), s, 2, '0'), nil
 }
 
 //| %S	| Second as a zero-padded decimal number.	| 05|
 //| %-S	| Second as a decimal number. (Platform specific)	| 5|
-func cvt_output_S(t time.Time, flags bool) (string, error) {
-    if flags {
-        return fmt.Sprintf("%d", t.Second()), nil
-    } else {
-        return fmt.Sprintf("%02d", t.Second()), nil
+func cvt_output_S(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    if s.mode&formatLeap != 0 {
+        return appendNumber(b, 60, s, 2, '0'), nil
     }
+    return appendNumber(b, t.Second(), s, 2, '0'), nil
 }
 
 //| %f	| Microsecond as a decimal number, zero-padded on the left.	| 000000|
-func cvt_output_f(t time.Time, flags bool) (string, error) {
-    return fmt.Sprintf("%06d", t.Nanosecond()/1000), nil
+//| %3f	| Fraction of the second with the given number of digits (here milliseconds).	| 000|
+func cvt_output_f(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendFraction(b, t.Nanosecond(), s, 6), nil
+}
+
+//| %N	| Nanosecond as a decimal number, zero-padded on the left. A width selects the number of digits as for %f.	| 000000000|
+func cvt_output_N(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendFraction(b, t.Nanosecond(), s, 9), nil
+}
+
+//| %L	| Millisecond as a decimal number, zero-padded on the left. A width selects the number of digits as for %f.	| 000|
+func cvt_output_L(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendFraction(b, t.Nanosecond(), s, 3), nil
+}
+
+// appendFraction appends the leading digits of the fraction of a second
+// nsec, truncated rather than rounded so a time never formats as a later
+// one. The spec's width is the number of digits, and digits the default.
+// Widths beyond nanoseconds are filled with zeros.
+func appendFraction(b []byte, nsec int, s spec, digits int) []byte {
+    if s.width > 0 {
+        digits = s.width
+    }
+    n := digits
+    if n > 9 {
+        n = 9
+    }
+    for i := n; i < 9; i++ {
+        nsec /= 10
+    }
+    b = appendInt(b, nsec, n)
+    for ; n < digits; n++ {
+        b = append(b, '0')
+    }
+    return b
 }
 
 //| %z	| UTC offset in the form +HHMM or -HHMM (empty string if the the object is naive).	| |
-func cvt_output_z(t time.Time, flags bool) (string, error) {
+//| %:z	| UTC offset in the form +HH:MM or -HH:MM.	| +05:30|
+//| %::z	| UTC offset in the form +HH:MM:SS or -HH:MM:SS, for local mean time offsets.	| +00:01:15|
+//| %:::z	| UTC offset with the minimal precision needed.	| +05:30|
+//| %Ez	| Like %z (or %E:z like %:z), but Z for UTC.	| Z|
+func cvt_output_z(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    if s.mode&formatNaive != 0 {
+        return b, nil
+    }
     _, o := t.Zone()
-    var pfx string
+    if o == 0 && s.mod == 'E' {
+        return append(b, 'Z'), nil
+    }
     if o >= 0 {
-        pfx = "+"
+        b = append(b, '+')
     } else {
-        pfx = "-"
+        b = append(b, '-')
         o = 0 - o
     }
-    return fmt.Sprintf("%s%02d%02d", pfx, o/3600, o%60), nil
+    h, m, sec := o/3600, o/60%60, o%60
+    b = appendInt(b, h, 2)
+    switch s.colons {
+    case 0:
+        b = appendInt(b, m, 2)
+    case 1:
+        b = append(b, ':')
+        b = appendInt(b, m, 2)
+    case 2:
+        b = append(b, ':')
+        b = appendInt(b, m, 2)
+        b = append(b, ':')
+        b = appendInt(b, sec, 2)
+    default:
+        if m != 0 || sec != 0 {
+            b = append(b, ':')
+            b = appendInt(b, m, 2)
+        }
+        if sec != 0 {
+            b = append(b, ':')
+            b = appendInt(b, sec, 2)
+        }
+    }
+    return b, nil
 }
 
 //| %Z	| Time zone name (empty string if the object is naive).	| |
-func cvt_output_Z(t time.Time, flags bool) (string, error) {
-    s, _ := t.Zone()
-    return s, nil
+func cvt_output_Z(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    if s.mode&formatNaive != 0 {
+        return b, nil
+    }
+    name, _ := t.Zone()
+    return append(b, name...), nil
 }
 
 //| %j	| Day of the year as a zero-padded decimal number.	| 273|
 //| %-j	| Day of the year as a decimal number. (Platform specific)	| 273|
-func cvt_output_j(t time.Time, flags bool) (string, error) {
-    if flags {
-        return fmt.Sprintf("%d", t.YearDay()), nil
-    } else {
-        return fmt.Sprintf("%03d", t.YearDay()), nil
-    }
+func cvt_output_j(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendNumber(b, t.YearDay(), s, 3, '0'), nil
 }
 
 //| %U	| Week number of the year (Sunday as the first day of the week) as a zero padded decimal number. All days in a new year preceding the first Sunday are considered to be in week 0.	| 39|
-func cvt_output_U(t time.Time, flags bool) (string, error) {
-    _, w := t.ISOWeek() //TODO: Need update.
-    return fmt.Sprintf("%02d", w), nil
+func cvt_output_U(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    w := (t.YearDay() + 6 - int(t.Weekday())) / 7
+    return appendNumber(b, w, s, 2, '0'), nil
 }
 
 //| %W	| Week number of the year (Monday as the first day of the week) as a decimal number. All days in a new year preceding the first Monday are considered to be in week 0.	| 39|
-func cvt_output_W(t time.Time, flags bool) (string, error) {
+func cvt_output_W(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    w := (t.YearDay() + 6 - (int(t.Weekday())+6)%7) / 7
+    return appendNumber(b, w, s, 2, '0'), nil
+}
+
+//| %C	| Century as a zero-padded decimal number.	| 20|
+//| %EC	| Name of the era of the locale’s calendar.	| 令和|
+func cvt_output_C(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    if d, ok := calendarDate(t, s, l); ok {
+        if names := l.Calendar.EraNames(d.Era); len(names) > 0 {
+            return append(b, names[0]...), nil
+        }
+    }
+    // The century is floored, so that %C%y gives the year: -44 is -1 and 56.
+    y := t.Year()
+    return appendNumber(b, (y-(y%100+100)%100)/100, s, 2, '0'), nil
+}
+
+// calendarDate returns the date of t in the calendar of l for a directive
+// with the E modifier, if l has a calendar with an era for t.
+func calendarDate(t time.Time, s spec, l *Locale) (CalendarDate, bool) {
+    if s.mod != 'E' || nil == l.Calendar {
+        return CalendarDate{}, false
+    }
+    return l.Calendar.Date(t)
+}
+
+//| %e	| Day of the month as a space-padded decimal number.	|  8|
+//| %-e	| Day of the month as a decimal number.	| 8|
+func cvt_output_e(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendNumber(b, t.Day(), s, 2, ' '), nil
+}
+
+//| %h	| Same as %b.	| Sep|
+func cvt_output_h(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return cvt_output_b(b, t, s, l)
+}
+
+//| %k	| Hour (24-hour clock) as a space-padded decimal number.	|  7|
+func cvt_output_k(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendNumber(b, t.Hour(), s, 2, ' '), nil
+}
+
+//| %l	| Hour (12-hour clock) as a space-padded decimal number.	|  7|
+func cvt_output_l(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendNumber(b, hour12(t), s, 2, ' '), nil
+}
+
+//| %P	| Like %p, but lower case.	| am|
+func cvt_output_P(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    n := len(b)
+    b, _ = cvt_output_p(b, t, s, l)
+    return changeCase(b, n, unicode.ToLower), nil
+}
+
+//| %s	| Seconds since the Epoch, 1970-01-01 00:00:00 UTC.	| 1380524765|
+func cvt_output_s(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendNumber(b, int(t.Unix()), s, 1, '0'), nil
+}
+
+//| %u	| ISO 8601 weekday as a decimal number, where 1 is Monday and 7 is Sunday.	| 1|
+func cvt_output_u(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    wd := int(t.Weekday())
+    if wd == 0 {
+        wd = 7
+    }
+    return appendNumber(b, wd, s, 1, '0'), nil
+}
+
+//| %G	| ISO 8601 week-based year with century as a decimal number.	| 2013|
+func cvt_output_G(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    y, _ := t.ISOWeek()
+    return appendNumber(b, y, s, 1, '0'), nil
+}
+
+//| %g	| ISO 8601 week-based year without century as a zero-padded decimal number.	| 13|
+func cvt_output_g(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    y, _ := t.ISOWeek()
+    return appendNumber(b, y%100, s, 2, '0'), nil
+}
+
+//| %V	| ISO 8601 week number of the year as a zero-padded decimal number. Week 1 is the week containing the first Thursday.	| 40|
+//| %-V	| ISO 8601 week number of the year as a decimal number.	| 40|
+func cvt_output_V(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
     _, w := t.ISOWeek()
-    return fmt.Sprintf("%02d", w), nil
+    return appendNumber(b, w, s, 2, '0'), nil
+}
+
+//| %J	| Era as locale’s name, BC for years before 1 and AD for the others.	| AD|
+func cvt_output_J(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return append(b, eraNames(l, t.Year() <= 0)[0]...), nil
+}
+
+//| %i	| Year of the era as a decimal number, where 1 BC is the year 0 of %Y.	| 2013|
+func cvt_output_i(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    y := t.Year()
+    if y <= 0 {
+        y = 1 - y
+    }
+    return appendNumber(b, y, s, 1, '0'), nil
+}
+
+//| %q	| Quarter of the year as a decimal number.	| 3|
+func cvt_output_q(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendNumber(b, (int(t.Month())+2)/3, s, 1, '0'), nil
+}
+
+//| %v	| Half of the year as a decimal number.	| 2|
+func cvt_output_v(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendNumber(b, (int(t.Month())+5)/6, s, 1, '0'), nil
+}
+
+//| %K	| Fiscal year of the locale with century as a decimal number.	| 2014|
+//| %:K	| Fiscal year of the locale without century as a zero-padded decimal number.	| 14|
+func cvt_output_K(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    y, _ := l.FiscalYear.Period(t)
+    if s.colons > 0 {
+        return appendNumber(b, (y%100+100)%100, s, 2, '0'), nil
+    }
+    if s.pad == '+' {
+        return appendNumber(b, y, s, 4, '0'), nil
+    }
+    return appendNumber(b, y, s, 1, '0'), nil
+}
+
+//| %Q	| Fiscal quarter of the locale as a decimal number.	| 4|
+func cvt_output_Q(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    _, p := l.FiscalYear.Period(t)
+    return appendNumber(b, (p+2)/3, s, 1, '0'), nil
+}
+
+//| %o	| Fiscal period of the locale as a zero-padded decimal number.	| 12|
+//| %-o	| Fiscal period of the locale as a decimal number.	| 12|
+func cvt_output_o(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    _, p := l.FiscalYear.Period(t)
+    return appendNumber(b, p, s, 2, '0'), nil
+}
+
+//| %n	| A newline character.	| |
+func cvt_output_n(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return append(b, '\n'), nil
+}
+
+//| %t	| A tab character.	| |
+func cvt_output_t(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return append(b, '\t'), nil
+}
+
+// composite_formats holds the directives that are shorthands for a fixed
+// format, shared by Strftime and Strptime.
+var composite_formats = map[rune]string{
+    //| %D	| Same as %m/%d/%y.	| 09/30/13|
+    'D': "%m/%d/%y",
+    //| %F	| Same as %Y-%m-%d (the ISO 8601 date format).	| 2013-09-30|
+    'F': "%Y-%m-%d",
+    //| %R	| Same as %H:%M.	| 07:06|
+    'R': "%H:%M",
+    //| %T	| Same as %H:%M:%S (the ISO 8601 time format).	| 07:06:05|
+    'T': "%H:%M:%S",
+    //| %+	| Date and time in date(1) format.	| Mon Sep 30 07:06:05 UTC 2013|
+    '+': "%a %b %e %H:%M:%S %Z %Y",
+}
+
+func cvt_output_D(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendStrftime(b, t, composite_formats['D'], l, s.mode, nil)
+}
+
+func cvt_output_F(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendStrftime(b, t, composite_formats['F'], l, s.mode, nil)
+}
+
+func cvt_output_R(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendStrftime(b, t, composite_formats['R'], l, s.mode, nil)
+}
+
+func cvt_output_T(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendStrftime(b, t, composite_formats['T'], l, s.mode, nil)
+}
+
+func cvt_output_plus(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendStrftime(b, t, composite_formats['+'], l, s.mode, nil)
 }
 
 //| %c	| Locale’s appropriate date and time representation.	| Mon Sep 30 07:06:05 2013|
-func cvt_output_c(t time.Time, flags bool) (string, error) {
-    a, _ := cvt_output_a(t, flags)
-    b, _ := cvt_output_b(t, flags)
-    d, _ := cvt_output_d(t, flags)
-    H, _ := cvt_output_H(t, flags)
-    M, _ := cvt_output_M(t, flags)
-    S, _ := cvt_output_S(t, flags)
-    Y, _ := cvt_output_Y(t, flags)
-    return fmt.Sprintf("%s %s %s %s:%s:%s %s", a, b, d, H, M, S, Y), nil
+func cvt_output_c(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    if pattern, ok := eraDatePattern(t, s, l); ok {
+        return appendPattern(b, t, pattern, l, s.mode)
+    }
+    return appendPattern(b, t, l.DateTimeFormat, l, s.mode)
 }
 
 //| %x	| Locale’s appropriate date representation.	| 09/30/13|
-func cvt_output_x(t time.Time, flags bool) (string, error) {
-    m, _ := cvt_output_m(t, flags)
-    d, _ := cvt_output_d(t, flags)
-    y, _ := cvt_output_y(t, flags)
-    return fmt.Sprintf("%s/%s/%s", m, d, y), nil
+func cvt_output_x(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    if pattern, ok := eraDatePattern(t, s, l); ok {
+        return appendPattern(b, t, pattern, l, s.mode)
+    }
+    return appendPattern(b, t, l.DateFormat, l, s.mode)
+}
+
+// eraDatePattern returns the pattern of %Ec or %Ex in the calendar of l, if
+// l has one with an era for t.
+func eraDatePattern(t time.Time, s spec, l *Locale) (string, bool) {
+    if _, ok := calendarDate(t, s, l); ok {
+        pattern, ok, _ := eraPattern(s.code, l)
+        return pattern, ok
+    }
+    return "", false
 }
 
 //| %X	| Locale’s appropriate time representation.	| 07:06:05|
-func cvt_output_X(t time.Time, flags bool) (string, error) {
-    H, _ := cvt_output_H(t, flags)
-    M, _ := cvt_output_M(t, flags)
-    S, _ := cvt_output_S(t, flags)
-    return fmt.Sprintf("%s:%s:%s", H, M, S), nil
+func cvt_output_X(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendPattern(b, t, l.TimeFormat, l, s.mode)
+}
+
+//| %r	| Locale’s 12-hour clock time.	| 07:06:05 AM|
+func cvt_output_r(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return appendPattern(b, t, l.TimeFormat12, l, s.mode)
+}
+
+// appendPattern expands one of the locale's preferred patterns. Patterns may
+// not refer to another preferred pattern, which would never terminate.
+func appendPattern(b []byte, t time.Time, pattern string, l *Locale, mode formatMode) ([]byte, error) {
+    for i := 0; i < len(pattern); i++ {
+        if pattern[i] != 0x25 { // "%" -> 0x25
+            continue
+        }
+        sp, next := cutDirective(pattern, i)
+        switch sp.code {
+        case 'c', 'x', 'X', 'r':
+            return b, errors.New("Recursive locale pattern:" + pattern)
+        }
+        i = next - 1
+    }
+    return appendStrftime(b, t, pattern, l, mode, nil)
 }
 
 //| %%	| A literal '%' character.	| %|
-func cvt_output_percent(t time.Time, flags bool) (string, error) {
-    return "%", nil
+func cvt_output_percent(b []byte, t time.Time, s spec, l *Locale) ([]byte, error) {
+    return append(b, '%'), nil
 }
 
-var ontput_converters = map[rune]func(time.Time, bool) (string, error){
+var ontput_converters = map[rune]func([]byte, time.Time, spec, *Locale) ([]byte, error){
     //| %a	| Weekday as locale’s abbreviated name.	| Mon|
     'a': cvt_output_a,
     //| %A	| Weekday as locale’s full name.	| Monday|
@@ -208,9 +484,8 @@ var ontput_converters = map[rune]func(time.Time, bool) (string, error){
     //| %-m	| Month as a decimal number. (Platform specific)	| 9|
     'm': cvt_output_m,
     //| %y	| Year without century as a zero-padded decimal number.	| 13|
+    //| %Ey	| Year of the era of the locale’s calendar as a decimal number.	| 8|
     'y': cvt_output_y,
-    //| %Y	| Year with century as a decimal number.	| 2013|
-    'Y': cvt_output_Y,
     //| %H	| Hour (24-hour clock) as a zero-padded decimal number.	| 07|
     //| %-H	| Hour (24-hour clock) as a decimal number. (Platform specific)	| 7|
     'H': cvt_output_H,
@@ -226,8 +501,17 @@ var ontput_converters = map[rune]func(time.Time, bool) (string, error){
     //| %-S	| Second as a decimal number. (Platform specific)	| 5|
     'S': cvt_output_S,
     //| %f	| Microsecond as a decimal number, zero-padded on the left.	| 000000|
+    //| %3f	| Fraction of the second with the given number of digits (here milliseconds).	| 000|
     'f': cvt_output_f,
+    //| %N	| Nanosecond as a decimal number, zero-padded on the left. A width selects the number of digits as for %f.	| 000000000|
+    'N': cvt_output_N,
+    //| %L	| Millisecond as a decimal number, zero-padded on the left. A width selects the number of digits as for %f.	| 000|
+    'L': cvt_output_L,
     //| %z	| UTC offset in the form +HHMM or -HHMM (empty string if the the object is naive).	| |
+    //| %:z	| UTC offset in the form +HH:MM or -HH:MM.	| +05:30|
+    //| %::z	| UTC offset in the form +HH:MM:SS or -HH:MM:SS, for local mean time offsets.	| +00:01:15|
+    //| %:::z	| UTC offset with the minimal precision needed.	| +05:30|
+    //| %Ez	| Like %z (or %E:z like %:z), but Z for UTC.	| Z|
     'z': cvt_output_z,
     //| %Z	| Time zone name (empty string if the object is naive).	| |
     'Z': cvt_output_Z,
@@ -238,47 +522,285 @@ var ontput_converters = map[rune]func(time.Time, bool) (string, error){
     'U': cvt_output_U,
     //| %W	| Week number of the year (Monday as the first day of the week) as a decimal number. All days in a new year preceding the first Monday are considered to be in week 0.	| 39|
     'W': cvt_output_W,
+    //| %C	| Century as a zero-padded decimal number.	| 20|
+    //| %EC	| Name of the era of the locale’s calendar.	| 令和|
+    'C': cvt_output_C,
+    //| %e	| Day of the month as a space-padded decimal number.	|  8|
+    //| %-e	| Day of the month as a decimal number.	| 8|
+    'e': cvt_output_e,
+    //| %h	| Same as %b.	| Sep|
+    'h': cvt_output_h,
+    //| %k	| Hour (24-hour clock) as a space-padded decimal number.	|  7|
+    'k': cvt_output_k,
+    //| %l	| Hour (12-hour clock) as a space-padded decimal number.	|  7|
+    'l': cvt_output_l,
+    //| %P	| Like %p, but lower case.	| am|
+    'P': cvt_output_P,
+    //| %s	| Seconds since the Epoch, 1970-01-01 00:00:00 UTC.	| 1380524765|
+    's': cvt_output_s,
+    //| %u	| ISO 8601 weekday as a decimal number, where 1 is Monday and 7 is Sunday.	| 1|
+    'u': cvt_output_u,
+    //| %G	| ISO 8601 week-based year with century as a decimal number.	| 2013|
+    'G': cvt_output_G,
+    //| %g	| ISO 8601 week-based year without century as a zero-padded decimal number.	| 13|
+    'g': cvt_output_g,
+    //| %V	| ISO 8601 week number of the year as a zero-padded decimal number. Week 1 is the week containing the first Thursday.	| 40|
+    //| %-V	| ISO 8601 week number of the year as a decimal number.	| 40|
+    'V': cvt_output_V,
+    //| %J	| Era as locale’s name, BC for years before 1 and AD for the others.	| AD|
+    'J': cvt_output_J,
+    //| %i	| Year of the era as a decimal number, where 1 BC is the year 0 of %Y.	| 2013|
+    'i': cvt_output_i,
+    //| %q	| Quarter of the year as a decimal number.	| 3|
+    'q': cvt_output_q,
+    //| %v	| Half of the year as a decimal number.	| 2|
+    'v': cvt_output_v,
+    //| %K	| Fiscal year of the locale with century as a decimal number.	| 2014|
+    //| %:K	| Fiscal year of the locale without century as a zero-padded decimal number.	| 14|
+    'K': cvt_output_K,
+    //| %Q	| Fiscal quarter of the locale as a decimal number.	| 4|
+    'Q': cvt_output_Q,
+    //| %o	| Fiscal period of the locale as a zero-padded decimal number.	| 12|
+    //| %-o	| Fiscal period of the locale as a decimal number.	| 12|
+    'o': cvt_output_o,
+    //| %n	| A newline character.	| |
+    'n': cvt_output_n,
+    //| %t	| A tab character.	| |
+    't': cvt_output_t,
+    //| %%	| A literal '%' character.	| %|
+    '%': cvt_output_percent,
+}
+
+func init() {
+    // The locale patterns, %EY and the composite directives are expanded
+    // through ontput_converters itself, so these can only be added once the
+    // table is initialized.
+    //| %Y	| Year with century as a decimal number.	| 2013|
+    //| %EY	| Year in the locale’s calendar, with the name of the era.	| 令和8年|
+    ontput_converters['Y'] = cvt_output_Y
     //| %c	| Locale’s appropriate date and time representation.	| Mon Sep 30 07:06:05 2013|
-    'c': cvt_output_c,
+    ontput_converters['c'] = cvt_output_c
     //| %x	| Locale’s appropriate date representation.	| 09/30/13|
-    'x': cvt_output_x,
+    ontput_converters['x'] = cvt_output_x
     //| %X	| Locale’s appropriate time representation.	| 07:06:05|
-    'X': cvt_output_X,
-    //| %%	| A literal '%' character.	| %|
-    '%': cvt_output_percent,
+    ontput_converters['X'] = cvt_output_X
+    //| %r	| Locale’s 12-hour clock time.	| 07:06:05 AM|
+    ontput_converters['r'] = cvt_output_r
+    //| %D	| Same as %m/%d/%y.	| 09/30/13|
+    ontput_converters['D'] = cvt_output_D
+    //| %F	| Same as %Y-%m-%d (the ISO 8601 date format).	| 2013-09-30|
+    ontput_converters['F'] = cvt_output_F
+    //| %R	| Same as %H:%M.	| 07:06|
+    ontput_converters['R'] = cvt_output_R
+    //| %T	| Same as %H:%M:%S (the ISO 8601 time format).	| 07:06:05|
+    ontput_converters['T'] = cvt_output_T
+    //| %+	| Date and time in date(1) format.	| Mon Sep 30 07:06:05 UTC 2013|
+    ontput_converters['+'] = cvt_output_plus
 }
 
-func Strftime(t time.Time, format string) (string, error) {
-    buf := bytes.Buffer{}
+// appendDirective runs a converter and applies the generic parts of the
+// directive's spec to its output: the field width for non-numeric results and
+// the ^ and # case modifiers. Numeric converters pad themselves through
+// appendNumber, so the width is already met when they return.
+func appendDirective(b []byte, t time.Time, s spec, cvt_func func([]byte, time.Time, spec, *Locale) ([]byte, error), l *Locale) ([]byte, error) {
+    n := len(b)
+    b, e := cvt_func(b, t, s, l)
+    if e != nil {
+        return b, e
+    }
+    if s.width > 0 && s.pad != '-' {
+        pad := byte(' ')
+        if s.pad == '0' {
+            pad = '0'
+        }
+        b = padLeft(b, n, s.width, pad)
+    }
+    if s.upper {
+        b = changeCase(b, n, unicode.ToUpper)
+    } else if s.swap {
+        // GNU swaps case by upper-casing mixed or lower case text (Sep, am)
+        // and lower-casing text that is already upper case (UTC, PM).
+        to := unicode.ToLower
+        for _, r := range string(b[n:]) {
+            if unicode.IsLower(r) {
+                to = unicode.ToUpper
+                break
+            }
+        }
+        b = changeCase(b, n, to)
+    }
+    return b, nil
+}
+
+// padLeft pads b[n:] on the left with pad until it is width characters long.
+func padLeft(b []byte, n int, width int, pad byte) []byte {
+    k := width - utf8.RuneCount(b[n:])
+    if k <= 0 {
+        return b
+    }
+    m := len(b)
+    for i := 0; i < k; i++ {
+        b = append(b, pad)
+    }
+    copy(b[n+k:], b[n:m])
+    for i := n; i < n+k; i++ {
+        b[i] = pad
+    }
+    return b
+}
+
+// changeCase maps every rune of b[n:] through to. The converted text is
+// appended and then moved into place, so no temporary buffer is needed.
+func changeCase(b []byte, n int, to func(rune) rune) []byte {
+    m := len(b)
+    for _, r := range string(b[n:m]) {
+        b = utf8.AppendRune(b, to(r))
+    }
+    return append(b[:n], b[m:]...)
+}
+
+// appendNumber appends v formatted according to the spec of a numeric
+// directive. width and pad are the directive's defaults, used unless the
+// spec gives its own: the - flag disables padding, _ pads with spaces, 0
+// pads with zeros, + pads with zeros after a sign and an explicit width
+// overrides the default one.
+func appendNumber(b []byte, v int, s spec, width int, pad byte) []byte {
+    switch s.pad {
+    case '-':
+        return appendPadded(b, v, 0, pad)
+    case '_':
+        pad = ' '
+    case '0':
+        pad = '0'
+    case '+':
+        // The width counts the sign.
+        if s.width > 0 {
+            width = s.width
+        } else {
+            width++
+        }
+        if v < 0 {
+            return appendPadded(b, v, width, '0')
+        }
+        return appendPadded(append(b, '+'), v, width-1, '0')
+    }
+    if s.width > 0 {
+        width = s.width
+    }
+    return appendPadded(b, v, width, pad)
+}
+
+// appendInt appends the decimal form of v to b, zero-padded on the left to
+// at least width digits. It is used instead of strconv/fmt so formatting a
+// directive never allocates.
+func appendInt(b []byte, v int, width int) []byte {
+    return appendPadded(b, v, width, '0')
+}
+
+// appendPadded is like appendInt but pads with the given byte.
+// Zeros go between the sign and the digits, spaces before the sign.
+func appendPadded(b []byte, v int, width int, pad byte) []byte {
+    u := uint(v)
+    neg := v < 0
+    if neg {
+        u = uint(-v)
+        width--
+    }
+    var buf [20]byte
+    i := len(buf)
+    for u >= 10 {
+        i--
+        q := u / 10
+        buf[i] = byte('0' + u - q*10)
+        u = q
+    }
+    i--
+    buf[i] = byte('0' + u)
+    if neg && pad == '0' {
+        b = append(b, '-')
+    }
+    for w := len(buf) - i; w < width; w++ {
+        b = append(b, pad)
+    }
+    if neg && pad != '0' {
+        b = append(b, '-')
+    }
+    return append(b, buf[i:]...)
+}
+
+// AppendStrftime is like Strftime but appends the textual representation to
+// b and returns the extended buffer, mirroring time.Time.AppendFormat.
+// Formatting into a buffer with enough capacity does not allocate.
+func AppendStrftime(b []byte, t time.Time, format string) ([]byte, error) {
+    return appendStrftime(b, t, format, English, 0, &registered)
+}
+
+// appendStrftime formats t with the names of l, as mode says. Directives not
+// built in are looked up in d, which is nil for the composite directives and
+// the patterns of the locale.
+func appendStrftime(b []byte, t time.Time, format string, l *Locale, mode formatMode, d *Dialect) ([]byte, error) {
     length := len(format)
-    for i, j := 0, 0; i < length; i += j + 1 {
+    for i := 0; i < length; {
         c := format[i]
-        j = 0
-        flag := false
-        //fmt.Printf("c > %c \n",c)
-        if c != 0x25 || (i+1) >= length { // "%" -> 0x25
-            buf.WriteByte(c)
+        if c != 0x25 { // "%" -> 0x25
+            b = append(b, c)
+            i++
             continue
         }
-        if format[i+1] == 0x2d { // "-" -> 0x2d
-            flag = true
-            j += 1
-        }
-        if (i + j + 1) < length {
-            //fmt.Printf("format[i+j+1]> %c \n",format[i+j+1])
-            if cvt_func, ok := ontput_converters[rune(format[i+j+1])]; ok {
-                s, e := cvt_func(t, flag)
-                if e != nil {
-                    return "", e
-                }
-                buf.WriteString(s)
-            } else {
-                return "", errors.New("Unknown Code:" + format[i+j:i+j+1])
+        sp, next := cutDirective(format, i)
+        sp.mode = mode
+        if sp.code < 0 {
+            b = append(b, incompleteDirective(format[i:next])...)
+        } else if cvt_func, ok := ontput_converters[sp.code]; ok {
+            if mode&(formatNoDate|formatNoClock) != 0 && mode&directiveNeeds[sp.code] != 0 {
+                return b, &FormatError{Format: format, Offset: i, Directive: format[i:next], Err: ErrMissingField}
+            }
+            var e error
+            if b, e = appendDirective(b, t, sp, cvt_func, l); e != nil {
+                return b, directiveError(format, i, next, e)
+            }
+        } else if dir, ok := d.lookup(sp.code); ok {
+            var e error
+            if b, e = appendDirective(b, t, sp, dir.output, l); e != nil {
+                return b, directiveError(format, i, next, e)
             }
-            j += 1
         } else {
-            buf.WriteByte(format[i+1])
+            return b, &FormatError{Format: format, Offset: i, Directive: format[i:next], Err: ErrUnknownDirective}
         }
+        i = next
+    }
+    return b, nil
+}
+
+// WriteStrftime writes t formatted according to format to w. It returns the
+// number of bytes written and any error encountered.
+func WriteStrftime(w io.Writer, t time.Time, format string) (int, error) {
+    var buf [64]byte
+    b, e := AppendStrftime(buf[:0], t, format)
+    if e != nil {
+        return 0, e
+    }
+    return w.Write(b)
+}
+
+// StrftimeLocale is like Strftime but takes names and the %c, %x and %X
+// patterns from l.
+func StrftimeLocale(t time.Time, format string, l *Locale) (string, error) {
+    var buf [64]byte
+    b, e := appendStrftime(buf[:0], t, format, l, 0, &registered)
+    if e != nil {
+        return "", e
+    }
+    return string(b), nil
+}
+
+// Strftime formats t according to format. When the same format is used
+// repeatedly, Compile it once and call Format.Format instead.
+func Strftime(t time.Time, format string) (string, error) {
+    var buf [64]byte
+    b, e := AppendStrftime(buf[:0], t, format)
+    if e != nil {
+        return "", e
     }
-    return buf.String(), nil
+    return string(b), nil
 }
@@ -2,131 +2,229 @@ package timefmt
 
 import (
 	"time"
-	"regexp"
-	"bytes"
 	"errors"
 	//"fmt"
+	"sort"
 	"strconv"
+	"strings"
 )
 
-var input_regexes = map[rune]string {
-	//| %a	| Weekday as locale’s abbreviated name.	| Mon|
-	'a': "(?P<a>Mon|Tue|Wed|Thu|Fri|Sat|Sun)",
-	//| %A	| Weekday as locale’s full name.	| Monday|
-	'A': "(?P<A>Monday|Tuesday|Wednesday|Thursday|Friday|Saturday|Sunday)",
+// inputField describes the text Strptime accepts for a directive. Numbers
+// are scanned with backtracking over their digits; other text is matched by
+// scan, which takes the longest match it can.
+type inputField struct {
+	group   byte   // the input_converters entry the text goes to, 0 for none
+	min     int    // digits of a number
+	max     int    // -1 for any number of digits
+	pad     byte   // default padding; ' ' allows a blank before a short number
+	width   int    // the width strict parsing enforces, 0 for none; the least
+	               // one for numbers of any length, which try it first
+	sign    bool   // a number may start with '-' or '+'
+	minus   bool   // a number may start with '-', before fewer digits than min
+	scan    func(s string) int // the length of the text at the start of s, or -1
+	pattern string // the text scan accepts, for errors
+}
+
+// input_fields holds the directives that do not depend on the locale; see
+// localeNames for the names and compileScan for %c, %x, %X and %r.
+var input_fields = map[rune]inputField {
 	//| %w	| Weekday as a decimal number, where 0 is Sunday and 6 is Saturday.	| 1|
-	//'w': "(?P<w>[0-6])",
+	'w': {group: 'w', min: 1, max: 1},
 	//| %d	| Day of the month as a zero-padded decimal number.	| 30|
 	//| %-d	| Day of the month as a decimal number. (Platform specific)	| 30|
-	'd': "(?P<d>[0-9]{1,2})",
-	//| %b	| Month as locale’s abbreviated name.	| Sep|
-	'b': "(?P<b>Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec)",
-	//| %B	| Month as locale’s full name.	| September|
-	'B': "(?P<B>January|February|March|April|May|June|July|August|September|October|November|December)",
+	'd': {group: 'd', min: 1, max: 2, pad: '0', width: 2},
 	//| %m	| Month as a zero-padded decimal number.	| 09|
 	//| %-m	| Month as a decimal number. (Platform specific)	| 9|
-	'm': "(?P<m>[0-9]{1,2})",
+	'm': {group: 'm', min: 1, max: 2, pad: '0', width: 2},
 	//| %y	| Year without century as a zero-padded decimal number.	| 13|
-	'y': "(?P<y>[0-9]{2})",
+	'y': {group: 'y', min: 2, max: 2, pad: '0', width: 2},
 	//| %Y	| Year with century as a decimal number.	| 2013|
-	'Y': "(?P<Y>[0-9]{4})",
+	'Y': {group: 'Y', min: 1, max: -1, pad: '0', width: 4, minus: true},
 	//| %H	| Hour (24-hour clock) as a zero-padded decimal number.	| 07|
 	//| %-H	| Hour (24-hour clock) as a decimal number. (Platform specific)	| 7|
-	'H': "(?P<H>[0-9]{1,2})",
+	'H': {group: 'H', min: 1, max: 2, pad: '0', width: 2},
 	//| %I	| Hour (12-hour clock) as a zero-padded decimal number.	| 07|
 	//| %-I	| Hour (12-hour clock) as a decimal number. (Platform specific)	| 7|
-	'I': "(?P<I>[0-9]{1,2})",
-	//| %p	| Locale’s equivalent of either AM or PM.	| AM|
-	'p': "(?P<p>AM|PM)",
+	'I': {group: 'I', min: 1, max: 2, pad: '0', width: 2},
 	//| %M	| Minute as a zero-padded decimal number.	| 06|
 	//| %-M	| Minute as a decimal number. (Platform specific)	| 6|
-	'M': "(?P<M>[0-9]{1,2})",
+	'M': {group: 'M', min: 1, max: 2, pad: '0', width: 2},
 	//| %S	| Second as a zero-padded decimal number.	| 05|
 	//| %-S	| Second as a decimal number. (Platform specific)	| 5|
-	'S': "(?P<S>[0-9]{1,2})",
+	'S': {group: 'S', min: 1, max: 2, pad: '0', width: 2},
 	//| %f	| Microsecond as a decimal number, zero-padded on the left.	| 000000|
-	'f': "(?P<f>[0-9]{6})",
+	//| %N	| Nanosecond as a decimal number, zero-padded on the left.	| 000000000|
+	//| %L	| Millisecond as a decimal number, zero-padded on the left.	| 000|
+	// All three accept from one to nine digits, whatever the width, but
+	// strict parsing takes exactly as many digits as the width says.
+	'f': {group: 'f', min: 1, max: 9, width: 6},
+	'N': {group: 'f', min: 1, max: 9, width: 9},
+	'L': {group: 'f', min: 1, max: 9, width: 3},
 	//| %z	| UTC offset in the form +HHMM or -HHMM (empty string if the the object is naive).	| |
-	'z': "(?P<z>+|-[0-9]{4})",
+	//| %:z	| UTC offset in the form +HH:MM or -HH:MM.	| +05:30|
+	// Any of +HHMM, +HH:MM, +HH, +HH:MM:SS, Z, GMT+8 or UTC+08:00 is accepted.
+	'z': {group: 'z', scan: scanOffset, pattern: "Z|(?:GMT|UTC)(?:[+-][0-9]{1,2}(?::?[0-9]{2}){0,2})?|[+-][0-9]{2}(?::?[0-9]{2}){0,2}"},
 	//| %Z	| Time zone name (empty string if the object is naive).	| |
-	'Z': "(?P<Z>[a-zA-Z/_]{3,})",
+	// IANA names, aliases such as US/Eastern and abbreviations such as CEST.
+	'Z': {group: 'Z', scan: scanZoneName, pattern: "[A-Za-z][A-Za-z0-9/_+-]+"},
 	//| %j	| Day of the year as a zero-padded decimal number.	| 273|
 	//| %-j	| Day of the year as a decimal number. (Platform specific)	| 273|
-	//'j': "(?P<j>[0-9]{1,3})",
+	'j': {group: 'j', min: 1, max: 3, pad: '0', width: 3},
 	//| %U	| Week number of the year (Sunday as the first day of the week) as a zero padded decimal number. All days in a new year preceding the first Sunday are considered to be in week 0.	| 39|
-	//'U': "(?P<U>[0-9]{1,2})",
+	'U': {group: 'U', min: 1, max: 2, pad: '0', width: 2},
 	//| %W	| Week number of the year (Monday as the first day of the week) as a decimal number. All days in a new year preceding the first Monday are considered to be in week 0.	| 39|
-	//'W': "(?P<W>[0-9]{1,2})",
-	//| %c	| Locale’s appropriate date and time representation.	| Mon Sep 30 07:06:05 2013|
-	'c': "(?P<a>Mon|Tue|Wed|Thu|Fri|Sat|Sun) (?P<b>Jan|Feb|Mar|Apr|May|Jun|Jul|Aug|Sep|Oct|Nov|Dec) (?P<d>[0-9]{2}) (?P<H>[0-9]{2}):(?P<M>[0-9]{2}):(?P<S>[0-9]{2}) (?P<Y>[0-9]{4})",
-	//| %x	| Locale’s appropriate date representation.	| 09/30/13|
-	'x': "(?P<m>[0-9]{2})/(?P<d>[0-9]{2})/(?P<y>[0-9]{2})",
-	//| %X	| Locale’s appropriate time representation.	| 07:06:05|
-	'X': "(?P<H>[0-9]{2}):(?P<M>[0-9]{2}):(?P<S>[0-9]{2})",
-	//| %%	| A literal '%' character.	| %|
-	'%': "",
+	'W': {group: 'W', min: 1, max: 2, pad: '0', width: 2},
+	//| %G	| ISO 8601 week-based year with century as a decimal number.	| 2013|
+	'G': {group: 'G', min: 4, max: 4, pad: '0', width: 4},
+	//| %g	| ISO 8601 week-based year without century as a zero-padded decimal number.	| 13|
+	'g': {group: 'g', min: 2, max: 2, pad: '0', width: 2},
+	//| %V	| ISO 8601 week number of the year as a zero-padded decimal number. Week 1 is the week containing the first Thursday.	| 40|
+	'V': {group: 'V', min: 1, max: 2, pad: '0', width: 2},
+	//| %u	| ISO 8601 weekday as a decimal number, where 1 is Monday and 7 is Sunday.	| 1|
+	'u': {group: 'u', min: 1, max: 1},
+	//| %C	| Century as a zero-padded decimal number.	| 20|
+	'C': {group: 'C', min: 1, max: 2, pad: '0', width: 2, minus: true},
+	//| %e	| Day of the month as a space-padded decimal number.	|  8|
+	'e': {group: 'd', min: 1, max: 2, pad: ' ', width: 2},
+	//| %k	| Hour (24-hour clock) as a space-padded decimal number.	|  7|
+	'k': {group: 'H', min: 1, max: 2, pad: ' ', width: 2},
+	//| %l	| Hour (12-hour clock) as a space-padded decimal number.	|  7|
+	'l': {group: 'I', min: 1, max: 2, pad: ' ', width: 2},
+	//| %s	| Seconds since the Epoch, 1970-01-01 00:00:00 UTC.	| 1380524765|
+	's': {group: 's', min: 1, max: -1, sign: true},
+	//| %i	| Year of the era as a decimal number, where 1 BC is the year 0 of %Y.	| 2013|
+	'i': {group: 'i', min: 1, max: -1},
+	//| %q	| Quarter of the year as a decimal number.	| 3|
+	'q': {group: 'q', min: 1, max: 1},
+	//| %v	| Half of the year as a decimal number.	| 2|
+	'v': {group: 'v', min: 1, max: 1},
+	//| %K	| Fiscal year of the locale with century as a decimal number.	| 2014|
+	'K': {group: 'K', min: 4, max: -1, pad: '0', width: 4},
+	//| %Q	| Fiscal quarter of the locale as a decimal number.	| 4|
+	'Q': {group: 'Q', min: 1, max: 1},
+	//| %o	| Fiscal period of the locale as a zero-padded decimal number.	| 12|
+	//| %-o	| Fiscal period of the locale as a decimal number.	| 12|
+	'o': {group: 'o', min: 1, max: 2, pad: '0', width: 2},
+	//| %n	| A newline character.	| |
+	'n': {scan: scanSpace, pattern: `\s*`},
+	//| %t	| A tab character.	| |
+	't': {scan: scanSpace, pattern: `\s*`},
 }
 
+//| %:K	| Fiscal year of the locale without century as a zero-padded decimal number.	| 14|
+// It shares the converter of %K, which tells them apart by their length.
+var shortFiscalYear = inputField{group: 'K', min: 2, max: 2, pad: '0', width: 2}
+
 type _DateTime struct {
 	year int
 	month time.Month
 	day, hour, min, sec, nsec int
 	loc *time.Location
+	// The fields the value provided, for ParseOptions.Reference to fill
+	// in the others.
+	hasYear, hasMonth, hasDay bool
+	hasHour, hasMin, hasSec, hasNsec bool
+	filledYear bool // the year is ParseOptions.Reference's
+	hour12 bool // the hour is from %I
+	zone rune // 'z', 'Z' or 's' if the value named a zone
 	pm bool
+	century int
+	hasCentury bool
+	shortYear, shortISOYear bool // %y or %g, waiting for their century
+	eraYear int // %i
+	hasEraYear bool
+	bc bool // %J
+	hasEra bool
+	weekday time.Weekday
+	hasWeekday bool
+	yday int
+	hasYday bool
+	week int // %U or %W
+	weekStartsMonday bool
+	hasWeek bool
+	isoYear, isoWeek int
+	hasISOYear, hasISOWeek bool
+	quarter, half int // %q and %v
+	hasQuarter, hasHalf bool
+	fiscalYear, fiscalQuarter, fiscalPeriod int
+	hasFiscalYear, hasFiscalQuarter, hasFiscalPeriod bool
+	shortFiscalYear bool // %:K, waiting for its century
+	calEra, calYear int // %EC and %Ey of the locale's calendar
+	hasCalEra, hasCalYear bool
 }
 
-var input_converters = map[rune]func(string, *_DateTime) error {
+var input_converters = map[rune]func(string, *_DateTime, *Locale) error {
 	//| %a	| Weekday as locale’s abbreviated name.	| Mon|
-	//'a': func(val string, t *_DateTime) error {
-	//	if nil == t {
-	//		return errors.New("invalid time parameter")
-	//	}
-	//	return nil
-	//},
+	'a': func(val string, t *_DateTime, l *Locale) error {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		for i, v := range l.ShortDayNames {
+			if strings.EqualFold(v, val) {
+				t.weekday, t.hasWeekday = time.Weekday(i), true
+				return nil
+			}
+		}
+		return errors.New("weekday abbreviated name not match")
+	},
 	//| %A	| Weekday as locale’s full name.	| Monday|
-	//'A': func(val string, t *_DateTime) error {
-	//	if nil == t {
-	//		return errors.New("invalid time parameter")
-	//	}
-	//	return nil
-	//},
+	'A': func(val string, t *_DateTime, l *Locale) error {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		for i, v := range l.LongDayNames {
+			if strings.EqualFold(v, val) {
+				t.weekday, t.hasWeekday = time.Weekday(i), true
+				return nil
+			}
+		}
+		return errors.New("weekday name not match")
+	},
 	//| %w	| Weekday as a decimal number, where 0 is Sunday and 6 is Saturday.	| 1|
-	//'w': func(val string, t *_DateTime) error {
-	//	if nil == t {
-	//		return errors.New("invalid time parameter")
-	//	}
-	//	return nil
-	//},
+	'w': func(val string, t *_DateTime, l *Locale) error {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		w, e := strconv.Atoi(val)
+		if nil == e && w > 6 {
+			return ErrOutOfRange
+		}
+		t.weekday, t.hasWeekday = time.Weekday(w), true
+		return e
+	},
 	//| %d	| Day of the month as a zero-padded decimal number.	| 30|
 	//| %-d	| Day of the month as a decimal number. (Platform specific)	| 30|
-	'd': func(val string, t *_DateTime) (e error) {
+	'd': func(val string, t *_DateTime, l *Locale) (e error) {
 		if nil == t {
 			return errors.New("invalid time parameter")
 		}
 		t.day, e = strconv.Atoi(val)
+		t.hasDay = true
 		return e
 	},
 	//| %b	| Month as locale’s abbreviated name.	| Sep|
-	'b': func(val string, t *_DateTime) error {
+	'b': func(val string, t *_DateTime, l *Locale) error {
 		if nil == t {
 			return errors.New("invalid time parameter")
 		}
-		for i, v := range shortMonthNames {
-			if v == val {
-				t.month = time.Month(i)
+		for i, v := range l.ShortMonthNames {
+			if strings.EqualFold(v, val) {
+				t.month = time.Month(i + 1)
+				t.hasMonth = true
 				return nil
 			}
 		}
 		return errors.New("month abbreviated name not match")
 	},
 	//| %B	| Month as locale’s full name.	| September|
-	'B': func(val string, t *_DateTime) error {
+	'B': func(val string, t *_DateTime, l *Locale) error {
 		if nil == t {
 			return errors.New("invalid time parameter")
 		}
-		for i, v := range longMonthNames {
-			if v == val {
-				t.month = time.Month(i)
+		for i, v := range l.LongMonthNames {
+			if strings.EqualFold(v, val) {
+				t.month = time.Month(i + 1)
+				t.hasMonth = true
 				return nil
 			}
 		}
@@ -134,200 +232,963 @@ var input_converters = map[rune]func(string, *_DateTime) error {
 	},
 	//| %m	| Month as a zero-padded decimal number.	| 09|
 	//| %-m	| Month as a decimal number. (Platform specific)	| 9|
-	'm': func(val string, t *_DateTime) (e error) {
+	'm': func(val string, t *_DateTime, l *Locale) (e error) {
 		if nil == t {
 			return errors.New("invalid time parameter")
 		}
 		if m, e := strconv.Atoi(val); nil == e {
 			t.month = time.Month(m)
+			t.hasMonth = true
 			return nil
 		}else{
 			return e
 		}
 	},
 	//| %y	| Year without century as a zero-padded decimal number.	| 13|
-	'y': func(val string, t *_DateTime) (e error) {
+	'y': func(val string, t *_DateTime, l *Locale) (e error) {
 		if nil == t {
 			return errors.New("invalid time parameter")
 		}
+		// The century is left to %C or ParseOptions.Century.
 		t.year, e = strconv.Atoi(val)
-		if t.year < 70 {
-			t.year += 2000
-		}else{
-			t.year += 1900
-		}
+		t.hasYear, t.shortYear = true, true
 		return e
 	},
 	//| %Y	| Year with century as a decimal number.	| 2013|
-	'Y': func(val string, t *_DateTime) (e error) {
+	'Y': func(val string, t *_DateTime, l *Locale) (e error) {
 		if nil == t {
 			return errors.New("invalid time parameter")
 		}
 		t.year, e = strconv.Atoi(val)
+		t.hasYear = true
 		return e
 	},
 	//| %H	| Hour (24-hour clock) as a zero-padded decimal number.	| 07|
 	//| %-H	| Hour (24-hour clock) as a decimal number. (Platform specific)	| 7|
-	'H': func(val string, t *_DateTime) (e error) {
+	'H': func(val string, t *_DateTime, l *Locale) (e error) {
 		if nil == t {
 			return errors.New("invalid time parameter")
 		}
 		t.hour, e = strconv.Atoi(val)
+		t.hasHour = true
 		return e
 	},
 	//| %I	| Hour (12-hour clock) as a zero-padded decimal number.	| 07|
 	//| %-I	| Hour (12-hour clock) as a decimal number. (Platform specific)	| 7|
-	'I': func(val string, t *_DateTime) (e error) {
+	'I': func(val string, t *_DateTime, l *Locale) (e error) {
 		if nil == t {
 			return errors.New("invalid time parameter")
 		}
 		t.hour, e = strconv.Atoi(val)
+		t.hasHour, t.hour12 = true, true
 		return e
 	},
 	//| %p	| Locale’s equivalent of either AM or PM.	| AM|
-	'p': func(val string, t *_DateTime) error {
+	'p': func(val string, t *_DateTime, l *Locale) error {
 		if nil == t {
 			return errors.New("invalid time parameter")
 		}
-		switch val {
-		case "PM":
-			t.pm = true
-		case "AM":
-			t.pm = false
+		// %P matches the lower case form of the same names.
+		am, pm := dayPeriods(l.AM, l.PM)
+		for _, v := range pm {
+			if strings.EqualFold(val, v) {
+				t.pm = true
+				return nil
+			}
 		}
-		return nil
+		for _, v := range am {
+			if strings.EqualFold(val, v) {
+				t.pm = false
+				return nil
+			}
+		}
+		return errors.New("day period not match")
 	},
 	//| %M	| Minute as a zero-padded decimal number.	| 06|
 	//| %-M	| Minute as a decimal number. (Platform specific)	| 6|
-	'M': func(val string, t *_DateTime) (e error) {
+	'M': func(val string, t *_DateTime, l *Locale) (e error) {
 		if nil == t {
 			return errors.New("invalid time parameter")
 		}
 		t.min, e = strconv.Atoi(val)
+		t.hasMin = true
 		return e
 	},
 	//| %S	| Second as a zero-padded decimal number.	| 05|
 	//| %-S	| Second as a decimal number. (Platform specific)	| 5|
-	'S': func(val string, t *_DateTime) (e error) {
+	'S': func(val string, t *_DateTime, l *Locale) (e error) {
 		if nil == t {
 			return errors.New("invalid time parameter")
 		}
 		t.sec, e = strconv.Atoi(val)
+		t.hasSec = true
 		return e
 	},
 	//| %f	| Microsecond as a decimal number, zero-padded on the left.	| 000000|
-	'f': func(val string, t *_DateTime) (e error) {
+	'f': func(val string, t *_DateTime, l *Locale) (e error) {
 		if nil == t {
 			return errors.New("invalid time parameter")
 		}
+		// The digits are a fraction of a second: "5" is 500ms, "000321"
+		// is 321µs and "000000321" is 321ns. A width above 9 pads with
+		// zeros, which are all a time.Time can hold.
+		if len(val) > 9 {
+			if strings.Trim(val[9:], "0") != "" {
+				return ErrOutOfRange
+			}
+			val = val[:9]
+		}
 		t.nsec, e = strconv.Atoi(val)
-		t.nsec *= 1000
+		t.hasNsec = true
+		for i := len(val); i < 9; i++ {
+			t.nsec *= 10
+		}
 		return e
 	},
 	//| %z	| UTC offset in the form +HHMM or -HHMM (empty string if the the object is naive).	| |
-	'z': func(val string, t *_DateTime) error {
+	'z': func(val string, t *_DateTime, l *Locale) error {
 		if nil == t {
 			return errors.New("invalid time parameter")
 		}
+		offset, e := parseOffset(val)
+		if nil != e {
+			return e
+		}
+		if offset == 0 {
+			t.setZone(time.UTC, 'z')
+		} else {
+			t.setZone(time.FixedZone("", offset), 'z')
+		}
 		return nil
 	},
 	//| %Z	| Time zone name (empty string if the object is naive).	| |
-	'Z': func(val string, t *_DateTime) (e error) {
+	'Z': func(val string, t *_DateTime, l *Locale) error {
 		if nil == t {
 			return errors.New("invalid time parameter")
 		}
-		if t.loc,e = time.LoadLocation(val); nil != e {
-			//fmt.Errorf("Failed: %s\n", e)
+		loc, e := ResolveZone(val)
+		if nil != e {
 			return e
-		}else{
-			//fmt.Printf("Location: %s\n", t.loc)
+		}
+		t.setZone(loc, 'Z')
+		return nil
+	},
+	//| %C	| Century as a zero-padded decimal number.	| 20|
+	'C': func(val string, t *_DateTime, l *Locale) (e error) {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		t.century, e = strconv.Atoi(val)
+		t.hasCentury = true
+		return e
+	},
+	//| %s	| Seconds since the Epoch, 1970-01-01 00:00:00 UTC.	| 1380524765|
+	's': func(val string, t *_DateTime, l *Locale) error {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		n, e := strconv.ParseInt(val, 10, 64)
+		if nil != e {
+			return e
+		}
+		// The instant is shown in a zone parsed before it, or else in UTC.
+		u := time.Unix(n, 0).UTC()
+		if t.zone == 'z' || t.zone == 'Z' {
+			u = u.In(t.loc)
+		} else {
+			t.loc, t.zone = time.UTC, 's'
+		}
+		t.year, t.month, t.day = u.Date()
+		t.hour, t.min, t.sec = u.Clock()
+		t.hasYear, t.hasMonth, t.hasDay = true, true, true
+		t.hasHour, t.hasMin, t.hasSec, t.hasNsec = true, true, true, true
+		return nil
+	},
+	//| %J	| Era as locale’s name, BC for years before 1 and AD for the others.	| AD|
+	'J': func(val string, t *_DateTime, l *Locale) error {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		for _, bc := range []bool{true, false} {
+			for _, v := range eraNames(l, bc) {
+				if strings.EqualFold(v, val) {
+					t.bc, t.hasEra = bc, true
+					return nil
+				}
+			}
+		}
+		return errors.New("era name not match")
+	},
+	//| %EC	| Name of the era of the locale’s calendar.	| 令和|
+	'E': func(val string, t *_DateTime, l *Locale) error {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		for era := 0; nil != l.Calendar; era++ {
+			names := l.Calendar.EraNames(era)
+			if nil == names {
+				break
+			}
+			for _, v := range names {
+				if strings.EqualFold(v, val) {
+					t.calEra, t.hasCalEra = era, true
+					return nil
+				}
+			}
+		}
+		return errors.New("era name not match")
+	},
+	//| %Ey	| Year of the era of the locale’s calendar as a decimal number.	| 8|
+	// The group of %Ey is 'e', %e going to that of %d.
+	'e': func(val string, t *_DateTime, l *Locale) (e error) {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		t.hasCalYear = true
+		if val == l.FirstEraYear {
+			t.calYear = 1
 			return nil
 		}
+		t.calYear, e = strconv.Atoi(val)
+		return e
+	},
+	//| %i	| Year of the era as a decimal number, where 1 BC is the year 0 of %Y.	| 2013|
+	'i': func(val string, t *_DateTime, l *Locale) (e error) {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		t.eraYear, e = strconv.Atoi(val)
+		t.hasEraYear = true
+		return e
 	},
 	//| %j	| Day of the year as a zero-padded decimal number.	| 273|
 	//| %-j	| Day of the year as a decimal number. (Platform specific)	| 273|
-	//'j': func(val string, t *_DateTime) error {
-	//	if nil == t {
-	//		return errors.New("invalid time parameter")
-	//	}
-	//	return nil
-	//},
+	'j': func(val string, t *_DateTime, l *Locale) (e error) {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		t.yday, e = strconv.Atoi(val)
+		t.hasYday = true
+		return e
+	},
 	//| %U	| Week number of the year (Sunday as the first day of the week) as a zero padded decimal number. All days in a new year preceding the first Sunday are considered to be in week 0.	| 39|
-	//'U': func(val string, t *_DateTime) error {
-	//	if nil == t {
-	//		return errors.New("invalid time parameter")
-	//	}
-	//	return nil
-	//},
+	'U': func(val string, t *_DateTime, l *Locale) (e error) {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		t.week, e = strconv.Atoi(val)
+		t.weekStartsMonday, t.hasWeek = false, true
+		return e
+	},
 	//| %W	| Week number of the year (Monday as the first day of the week) as a decimal number. All days in a new year preceding the first Monday are considered to be in week 0.	| 39|
-	//'W': func(val string, t *_DateTime) error {
-	//	if nil == t {
-	//		return errors.New("invalid time parameter")
-	//	}
-	//	return nil
-	//},
+	'W': func(val string, t *_DateTime, l *Locale) (e error) {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		t.week, e = strconv.Atoi(val)
+		t.weekStartsMonday, t.hasWeek = true, true
+		return e
+	},
+	//| %G	| ISO 8601 week-based year with century as a decimal number.	| 2013|
+	'G': func(val string, t *_DateTime, l *Locale) (e error) {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		t.isoYear, e = strconv.Atoi(val)
+		t.hasISOYear = true
+		return e
+	},
+	//| %g	| ISO 8601 week-based year without century as a zero-padded decimal number.	| 13|
+	'g': func(val string, t *_DateTime, l *Locale) (e error) {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		t.isoYear, e = strconv.Atoi(val)
+		t.hasISOYear, t.shortISOYear = true, true
+		return e
+	},
+	//| %V	| ISO 8601 week number of the year as a zero-padded decimal number. Week 1 is the week containing the first Thursday.	| 40|
+	'V': func(val string, t *_DateTime, l *Locale) (e error) {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		t.isoWeek, e = strconv.Atoi(val)
+		t.hasISOWeek = true
+		return e
+	},
+	//| %q	| Quarter of the year as a decimal number.	| 3|
+	'q': func(val string, t *_DateTime, l *Locale) error {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		q, e := strconv.Atoi(val)
+		if nil == e && (q < 1 || q > 4) {
+			return ErrOutOfRange
+		}
+		t.quarter, t.hasQuarter = q, true
+		return e
+	},
+	//| %v	| Half of the year as a decimal number.	| 2|
+	'v': func(val string, t *_DateTime, l *Locale) error {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		h, e := strconv.Atoi(val)
+		if nil == e && (h < 1 || h > 2) {
+			return ErrOutOfRange
+		}
+		t.half, t.hasHalf = h, true
+		return e
+	},
+	//| %K	| Fiscal year of the locale with century as a decimal number.	| 2014|
+	//| %:K	| Fiscal year of the locale without century as a zero-padded decimal number.	| 14|
+	'K': func(val string, t *_DateTime, l *Locale) (e error) {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		// %K has at least four digits, %:K two.
+		t.fiscalYear, e = strconv.Atoi(val)
+		t.hasFiscalYear, t.shortFiscalYear = true, len(val) == 2
+		return e
+	},
+	//| %Q	| Fiscal quarter of the locale as a decimal number.	| 4|
+	'Q': func(val string, t *_DateTime, l *Locale) error {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		q, e := strconv.Atoi(val)
+		if nil == e && (q < 1 || q > 4) {
+			return ErrOutOfRange
+		}
+		t.fiscalQuarter, t.hasFiscalQuarter = q, true
+		return e
+	},
+	//| %o	| Fiscal period of the locale as a zero-padded decimal number.	| 12|
+	'o': func(val string, t *_DateTime, l *Locale) error {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		p, e := strconv.Atoi(val)
+		if nil == e && (p < 1 || p > 12) {
+			return ErrOutOfRange
+		}
+		t.fiscalPeriod, t.hasFiscalPeriod = p, true
+		return e
+	},
+	//| %u	| ISO 8601 weekday as a decimal number, where 1 is Monday and 7 is Sunday.	| 1|
+	'u': func(val string, t *_DateTime, l *Locale) error {
+		if nil == t {
+			return errors.New("invalid time parameter")
+		}
+		u, e := strconv.Atoi(val)
+		if nil == e && (u < 1 || u > 7) {
+			return ErrOutOfRange
+		}
+		t.weekday, t.hasWeekday = time.Weekday(u%7), true
+		return e
+	},
+}
+
+// parseOffset returns the number of seconds east of UTC of an offset matched
+// by %z. The hours may have one digit only after GMT or UTC (GMT+8).
+func parseOffset(val string) (int, error) {
+	if val == "Z" {
+		return 0, nil
+	}
+	s := val
+	if strings.HasPrefix(s, "GMT") || strings.HasPrefix(s, "UTC") {
+		s = s[3:]
+		if s == "" {
+			return 0, nil
+		}
+	}
+	sign := 1
+	if s[0] == '-' {
+		sign = -1
+	}
+	s = s[1:]
+	var fields []string
+	if strings.IndexByte(s, ':') >= 0 {
+		fields = strings.Split(s, ":")
+	} else {
+		// Without colons, hours take whatever the two-digit minutes and
+		// seconds leave: 8, 08, 830, 0830 or 083000.
+		n := len(s) % 2
+		if n == 0 {
+			n = 2
+		}
+		fields = append(fields, s[:n])
+		for i := n; i < len(s); i += 2 {
+			fields = append(fields, s[i:i+2])
+		}
+	}
+	var hms [3]int
+	for i, f := range fields {
+		v, e := strconv.Atoi(f)
+		if nil != e {
+			return 0, e
+		}
+		hms[i] = v
+	}
+	if hms[0] > 23 || hms[1] > 59 || hms[2] > 59 {
+		return 0, ErrOutOfRange
+	}
+	return sign * (hms[0]*3600 + hms[1]*60 + hms[2]), nil
 }
 
-func buildRegexp(format string) (*regexp.Regexp, error) {
-	buf := bytes.Buffer{}
-	length := len(format)
-	for i, j := 0, 0; i < length; i+=j+1 {
-		c := format[i]
-		j = 0
-		//fmt.Printf("c > %c \n",c)
-		if c != 0x25 || (i+1) >= length { // "%" -> 0x25
-			buf.WriteByte(c)
+// resolveDate completes the date named by an ISO week date (%G, %V and %u),
+// by a %U/%W week number and weekday, or by a day of the year (%j), and
+// checks that it agrees with the month, day and weekday the value gives. A
+// missing weekday stands for the first day of the week. It returns the
+// groups of the directives to blame if the fields disagree, or "".
+func (t *_DateTime) resolveDate() string {
+	var date time.Time
+	var by string
+	switch {
+	case t.hasISOYear && t.hasISOWeek:
+		dow := 0 // days since Monday
+		if t.hasWeekday {
+			dow = (int(t.weekday) + 6) % 7
+		}
+		// Week 1 is the week holding January 4th.
+		jan4 := time.Date(t.isoYear, 1, 4, 0, 0, 0, 0, time.UTC)
+		yday := 4 - (int(jan4.Weekday())+6)%7 + (t.isoWeek-1)*7 + dow
+		date, by = time.Date(t.isoYear, 1, yday, 0, 0, 0, 0, time.UTC), "V"
+	case t.hasWeek:
+		// Days before the first Sunday (%U) or Monday (%W) are in week 0.
+		first := int(time.Date(t.year, 1, 1, 0, 0, 0, 0, time.UTC).Weekday())
+		dow := 0
+		if t.hasWeekday {
+			dow = int(t.weekday)
+		}
+		by = "U"
+		if t.weekStartsMonday {
+			first, dow, by = (first+6)%7, (dow+6)%7, "W"
+		}
+		var yday int
+		if t.week == 0 {
+			yday = 1 + dow - first
+		} else {
+			yday = 1 + (7-first)%7 + 7*(t.week-1) + dow
+		}
+		date = time.Date(t.year, 1, yday, 0, 0, 0, 0, time.UTC)
+	case t.hasYday:
+		date, by = time.Date(t.year, 1, t.yday, 0, 0, 0, 0, time.UTC), "j"
+	}
+	if by != "" {
+		year, month, day := date.Date()
+		if (t.hasYear && year != t.year) || (t.hasMonth && month != t.month) || (t.hasDay && day != t.day) {
+			return by
+		}
+		if t.hasYday && date.YearDay() != t.yday {
+			return "j"
+		}
+		t.year, t.month, t.day = year, month, day
+		t.hasYear, t.hasMonth, t.hasDay = true, true, true
+	}
+	if t.hasWeekday && t.hasYear && t.hasMonth && t.hasDay {
+		if time.Date(t.year, t.month, t.day, 0, 0, 0, 0, time.UTC).Weekday() != t.weekday {
+			return "aAwu"
+		}
+	}
+	return ""
+}
+
+// resolvePeriods completes the date from the fiscal year of %K and its
+// period (%o) or quarter (%Q) as fy says, or from the quarter (%q) or half
+// (%v) of the year, starting the date on the first day of the period. It
+// returns the groups of the directives to blame if they disagree with each
+// other or with the date, or "".
+func (t *_DateTime) resolvePeriods(fy *FiscalYear) string {
+	if t.hasFiscalQuarter && t.hasFiscalPeriod && (t.fiscalPeriod+2)/3 != t.fiscalQuarter {
+		return "Qo"
+	}
+	if t.hasFiscalYear && !(t.hasYear && t.hasMonth && t.hasDay) {
+		period := 1
+		if t.hasFiscalPeriod {
+			period = t.fiscalPeriod
+		} else if t.hasFiscalQuarter {
+			period = 3*t.fiscalQuarter - 2
+		}
+		year, month, day := fy.PeriodStart(t.fiscalYear, period, time.UTC).Date()
+		if (t.hasYear && year != t.year) || (t.hasMonth && month != t.month) {
+			return "KQo"
+		}
+		if !t.hasDay {
+			t.day, t.hasDay = day, true
+		}
+		t.year, t.month = year, month
+		t.hasYear, t.hasMonth = true, true
+	}
+	for _, p := range []struct {
+		has bool
+		n, months int
+		group string
+	}{
+		{t.hasQuarter, t.quarter, 3, "q"},
+		{t.hasHalf, t.half, 6, "v"},
+	} {
+		if !p.has {
 			continue
 		}
-		if format[i+1] == 0x2d { // "-" -> 0x2d
-			j += 1
+		first := time.Month(p.months*(p.n-1) + 1)
+		if t.hasMonth && (t.month < first || t.month >= first+time.Month(p.months)) {
+			return p.group
+		}
+		if !t.hasMonth {
+			t.month, t.hasMonth = first, true
+			if !t.hasDay {
+				t.day, t.hasDay = 1, true
+			}
+		}
+	}
+	if (t.hasFiscalYear || t.hasFiscalQuarter || t.hasFiscalPeriod) && t.hasYear && t.hasMonth && t.hasDay {
+		year, period := fy.period(t.year, t.month, t.day)
+		switch {
+		case t.hasFiscalYear && year != t.fiscalYear:
+			return "K"
+		case t.hasFiscalQuarter && (period+2)/3 != t.fiscalQuarter:
+			return "Q"
+		case t.hasFiscalPeriod && period != t.fiscalPeriod:
+			return "o"
+		}
+	}
+	return ""
+}
+
+// fieldGroups returns the groups of the fields a directive of the given
+// group sets: those of the date and time for %s, else its own.
+func fieldGroups(group byte) string {
+	if group == 's' {
+		return "YmdHMS"
+	}
+	return string(group)
+}
+
+// field returns the value of the field a directive of the given group sets
+// and the class of directives that must agree on it: "%d" with "%e", "%b"
+// with "%m", "%a" with "%w" and any directive with itself when repeated.
+// The class is 0 for directives that are not checked, such as %z, or %K,
+// which shares its group with %:K.
+func (t *_DateTime) field(group byte) (int, byte) {
+	switch group {
+	case 'd':
+		return t.day, 'd'
+	case 'm', 'b', 'B':
+		return int(t.month), 'm'
+	case 'a', 'A', 'w', 'u':
+		return int(t.weekday), 'w'
+	case 'Y':
+		return t.year, 'Y'
+	case 'y': // before its century
+		return t.year, 'y'
+	case 'H':
+		return t.hour, 'H'
+	case 'I': // before AM or PM
+		return t.hour, 'I'
+	case 'M':
+		return t.min, 'M'
+	case 'S':
+		return t.sec, 'S'
+	case 'f':
+		return t.nsec, 'f'
+	case 'j':
+		return t.yday, 'j'
+	case 'i':
+		return t.eraYear, 'i'
+	case 'E':
+		return t.calEra, 'E'
+	case 'e':
+		return t.calYear, 'e'
+	case 'J':
+		if t.bc {
+			return 1, 'J'
+		}
+		return 0, 'J'
+	case 'U', 'W':
+		return t.week, group
+	case 'V':
+		return t.isoWeek, 'V'
+	case 'G', 'g':
+		return t.isoYear, group
+	case 'C':
+		return t.century, 'C'
+	case 'q':
+		return t.quarter, 'q'
+	case 'v':
+		return t.half, 'v'
+	case 'Q':
+		return t.fiscalQuarter, 'Q'
+	case 'o':
+		return t.fiscalPeriod, 'o'
+	case 'p':
+		if t.pm {
+			return 1, 'p'
+		}
+		return 0, 'p'
+	}
+	return 0, 0
+}
+
+// resolveCentury completes the years of %y, %g and %:K with the century of %C or,
+// failing that, of opts.Century; %C alone is the first year of the century.
+// It returns 'C' if %C disagrees with a full year, or 0.
+func (t *_DateTime) resolveCentury(opts ParseOptions) byte {
+	if t.hasCentury {
+		// A full year, from %Y or %s, must be in the century.
+		// Both are floored, as %C and %y write them: -44 is -1 and 56.
+		yy := (t.year%100 + 100) % 100
+		if t.hasYear && !t.shortYear && t.year-yy != t.century*100 {
+			return 'C'
+		}
+		if !t.hasYear {
+			// A century alone is its first year, not the Reference's.
+			yy, t.filledYear = 0, false
+		}
+		t.year = t.century*100 + yy
+		t.hasYear, t.shortYear = true, false
+	}
+	if !t.shortYear && !t.shortISOYear && !t.shortFiscalYear {
+		return 0
+	}
+	rule, ref := opts.Century, opts.Reference
+	if nil == rule {
+		rule = defaultCentury
+	}
+	if ref.IsZero() {
+		ref = time.Now()
+	}
+	if nil != opts.Location {
+		ref = ref.In(opts.Location)
+	}
+	if t.shortYear {
+		t.year = rule(t.year, ref)
+	}
+	if t.shortISOYear {
+		t.isoYear = rule(t.isoYear, ref)
+	}
+	if t.shortFiscalYear {
+		t.fiscalYear = rule(t.fiscalYear, ref)
+	}
+	return 0
+}
+
+// resolveEra turns the year of an era (%i) into the year, before year 1 if
+// the era (%J) says so, and checks that the era agrees with a year given by
+// %Y. It returns the group to blame if they disagree, or 0.
+func (t *_DateTime) resolveEra() byte {
+	if t.hasEraYear {
+		year := t.eraYear
+		if t.bc {
+			year = 1 - year
+		}
+		if t.hasYear && year != t.year {
+			return 'i'
+		}
+		t.year, t.hasYear = year, true
+	} else if t.hasEra && t.hasYear && t.bc != (t.year <= 0) {
+		return 'J'
+	}
+	return 0
+}
+
+// setZone sets the zone of t, moving the fields of a %s parsed before it to
+// the same instant in loc.
+func (t *_DateTime) setZone(loc *time.Location, zone rune) {
+	if t.zone == 's' {
+		u := time.Date(t.year, t.month, t.day, t.hour, t.min, t.sec, t.nsec, t.loc).In(loc)
+		t.year, t.month, t.day = u.Date()
+		t.hour, t.min, t.sec = u.Clock()
+	}
+	t.loc, t.zone = loc, zone
+}
+
+// resolveCalendar completes the year from the era and year of the era of
+// cal, returning the group to blame and the error if they are missing, out
+// of range when strict or at odds with the other fields, or 0 and nil.
+func (t *_DateTime) resolveCalendar(cal Calendar, strict bool) (byte, error) {
+	if nil == cal || (!t.hasCalEra && !t.hasCalYear) {
+		return 0, nil
+	}
+	month, day := time.January, 1
+	if t.hasMonth {
+		month = t.month
+	}
+	if t.hasDay {
+		day = t.day
+	}
+	if !t.hasCalYear {
+		// An era alone must be that of the date.
+		if t.hasYear && t.hasMonth && t.hasDay {
+			d, ok := cal.Date(time.Date(t.year, month, day, 0, 0, 0, 0, time.UTC))
+			if !ok || d.Era != t.calEra {
+				return 'E', ErrInconsistent
+			}
+		}
+		return 0, nil
+	}
+	if !t.hasCalEra {
+		return 'e', ErrMissingField
+	}
+	g, ok := cal.Gregorian(CalendarDate{Era: t.calEra, Year: t.calYear, Month: month, Day: day})
+	if t.hasYear && t.year != g.Year {
+		return 'e', ErrInconsistent
+	}
+	if strict && !ok && t.hasMonth && t.hasDay && g.IsValid() {
+		return 'e', ErrOutOfRange
+	}
+	t.year, t.hasYear = g.Year, true
+	return 0, nil
+}
+
+// validate returns the group of the first field out of its range, or 0, for
+// strict parsing to report before time.Date gets to normalise it. It runs
+// once the year is complete but before the date is resolved.
+func (t *_DateTime) validate() byte {
+	if t.hasMonth && (t.month < 1 || t.month > 12) {
+		return 'm'
+	}
+	if t.hasDay {
+		last := 31
+		if t.hasMonth {
+			// With no year, February may have its leap day, unless the
+			// year of the Reference fills it in.
+			year := t.year
+			if !t.hasYear && !t.filledYear {
+				year = 2000
+			}
+			last = time.Date(year, t.month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		}
+		if t.day < 1 || t.day > last {
+			return 'd'
+		}
+	}
+	if t.hasHour && t.hour12 && (t.hour < 1 || t.hour > 12) {
+		return 'I'
+	}
+	if t.hasHour && t.hour > 23 {
+		return 'H'
+	}
+	if t.hasMin && t.min > 59 {
+		return 'M'
+	}
+	if t.hasSec && t.sec > 60 { // 60 is left to ParseOptions.LeapSecond
+		return 'S'
+	}
+	if t.hasEraYear && t.eraYear < 1 {
+		return 'i'
+	}
+	if t.hasYday {
+		days := 366
+		if t.hasYear && time.Date(t.year, 12, 31, 0, 0, 0, 0, time.UTC).YearDay() == 365 {
+			days = 365
+		}
+		if t.yday < 1 || t.yday > days {
+			return 'j'
+		}
+	}
+	if t.hasWeek && t.week > 53 {
+		if t.weekStartsMonday {
+			return 'W'
+		}
+		return 'U'
+	}
+	if t.hasISOWeek {
+		// Only years starting on a Thursday, or leap years starting on a
+		// Wednesday, have an ISO week 53.
+		weeks := 52
+		if t.hasISOYear {
+			if _, w := time.Date(t.isoYear, 12, 28, 0, 0, 0, 0, time.UTC).ISOWeek(); w == 53 {
+				weeks = 53
+			}
+		} else {
+			weeks = 53
+		}
+		if t.isoWeek < 1 || t.isoWeek > weeks {
+			return 'V'
 		}
-		if (i+j+1) < length {
-			//fmt.Printf("format[i+j+1]> %c \n",format[i+j+1])
-			if pattern, ok := input_regexes[rune(format[i+j+1])]; ok {
+	}
+	return 0
+}
 
-				buf.WriteString(pattern)
-			}else{
-				return nil, errors.New("Unknown Code:"+format[i+j:i+j+1])
+// fillFrom takes the fields the value did not provide from ref, as described
+// for ParseOptions.Reference.
+func (t *_DateTime) fillFrom(ref time.Time) {
+	if !t.hasYear {
+		t.year, t.filledYear = ref.Year(), true
+	}
+	if !t.hasMonth {
+		t.month = ref.Month()
+	}
+	if !t.hasDay {
+		t.day = ref.Day()
+		// The 31st of the reference month is the last day of February.
+		if last := time.Date(t.year, t.month+1, 0, 0, 0, 0, 0, time.UTC).Day(); t.day > last {
+			t.day = last
+		}
+	}
+	// Fields below the smallest one parsed stay zero: "10:30" is 10:30:00.
+	clock := [4]*int{&t.hour, &t.min, &t.sec, &t.nsec}
+	has := [4]bool{t.hasHour, t.hasMin, t.hasSec, t.hasNsec}
+	smallest := len(has)
+	for i := len(has) - 1; i >= 0; i-- {
+		if has[i] {
+			smallest = i
+			break
+		}
+	}
+	hour, min, sec := ref.Clock()
+	values := [4]int{hour, min, sec, ref.Nanosecond()}
+	for i := 0; i < smallest; i++ {
+		if !has[i] {
+			*clock[i] = values[i]
+		}
+	}
+}
+
+// localeNames returns the names a directive accepts, longest first so that
+// a name is never cut short by one of its prefixes ("mar" and "mars"), and
+// the input_converters entry they go to. It returns false if code does not
+// name anything.
+func localeNames(code rune, l *Locale) ([]string, byte, bool) {
+	var names []string
+	group := byte(code)
+	switch code {
+	//| %a	| Weekday as locale’s abbreviated name.	| Mon|
+	case 'a':
+		names = l.ShortDayNames[:]
+	//| %A	| Weekday as locale’s full name.	| Monday|
+	case 'A':
+		names = l.LongDayNames[:]
+	//| %b	| Month as locale’s abbreviated name.	| Sep|
+	case 'b':
+		names = l.ShortMonthNames[:]
+	//| %B	| Month as locale’s full name.	| September|
+	case 'B':
+		names = l.LongMonthNames[:]
+	//| %h	| Same as %b.	| Sep|
+	case 'h':
+		names, group = l.ShortMonthNames[:], 'b'
+	//| %p	| Locale’s equivalent of either AM or PM.	| AM|
+	case 'p':
+		am, pm := dayPeriods(l.AM, l.PM)
+		names = append(am, pm...)
+	//| %P	| Like %p, but lower case.	| am|
+	case 'P':
+		am, pm := dayPeriods(strings.ToLower(l.AM), strings.ToLower(l.PM))
+		names, group = append(am, pm...), 'p'
+	//| %J	| Era as locale’s name, BC for years before 1 and AD for the others.	| AD|
+	case 'J':
+		names = append(append(names, eraNames(l, true)...), eraNames(l, false)...)
+	default:
+		return nil, 0, false
+	}
+	sorted := make([]string, len(names))
+	copy(sorted, names)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i]) > len(sorted[j])
+	})
+	return sorted, group, true
+}
+
+// dayPeriods returns the spellings %p accepts for the day periods am and pm:
+// the names themselves and, for names made of Latin letters such as "AM" or
+// "a. m.", the bare letters ("AM"), the letters with dots ("A.M." and
+// "A. M.") and, if the two differ in it, the first letter ("A").
+func dayPeriods(am string, pm string) ([]string, []string) {
+	var spell = func(name string) []string {
+		var letters []string
+		for _, r := range name {
+			switch {
+			case r == '.' || r == ' ':
+			case ('A' <= r && r <= 'Z') || ('a' <= r && r <= 'z'):
+				letters = append(letters, string(r))
+			default:
+				return []string{name}
 			}
-			j += 1
-		}else{
-			buf.WriteByte(format[i+1])
 		}
+		if len(letters) == 0 {
+			return []string{name}
+		}
+		names := []string{name}
+		for _, v := range []string{
+			strings.Join(letters, ""),
+			strings.Join(letters, ".") + ".",
+			strings.Join(letters, ". ") + ".",
+		} {
+			if v != name {
+				names = append(names, v)
+			}
+		}
+		return names
 	}
-	return regexp.Compile(buf.String())
+	a, p := spell(am), spell(pm)
+	if len(a) > 1 && len(p) > 1 && !strings.EqualFold(a[1][:1], p[1][:1]) {
+		a, p = append(a, a[1][:1]), append(p, p[1][:1])
+	}
+	return a, p
 }
 
+// Strptime parses value according to format. When the same format is used
+// repeatedly, Compile it once and call Format.Parse instead.
 func Strptime(value string, format string) (time.Time, error) {
-	re, e := buildRegexp(format)
+	f, e := Compile(format)
 	if nil != e {
 		return time.Time{}, e
 	}
-	dt := &_DateTime{}
-	dt.loc, _ = time.LoadLocation("UTC")
-	match := re.FindStringSubmatch(value)
-	if len(match) > 0 {
-		for i, name := range re.SubexpNames() {
-			if i != 0 {
-				//fmt.Printf("Matched:(%s): %s \n", name, match[i])
-				c := rune(name[0])
-				if cvt_func, ok := input_converters[c]; ok {
-					if e = cvt_func(match[i], dt); e != nil{
-						//fmt.Errorf("Call '%s' function failed: %s \n", name, e)
-						return time.Time{}, e
-					}
-				}else{
-					return time.Time{}, errors.New("Invalid directive:"+string(c))
-				}
-			}
-		}
-	}else{
-		return time.Time{}, errors.New("can not match string with given format")
+	return f.Parse(value)
+}
+
+// StrptimeWithOptions is like Strptime but completes the time as opts says:
+// a value without a zone is read in opts.Location and the fields the format
+// omits are taken from opts.Reference.
+func StrptimeWithOptions(value string, format string, opts ParseOptions) (time.Time, error) {
+	f, e := Compile(format)
+	if nil != e {
+		return time.Time{}, e
 	}
+	return f.ParseWithOptions(value, opts)
+}
 
-	if dt.pm && dt.hour < 12 {
-		dt.hour += 12
+// StrptimeLocale is like Strptime but matches names and the %c, %x and %X
+// patterns of l.
+func StrptimeLocale(value string, format string, l *Locale) (time.Time, error) {
+	f, e := compile(format, l, &registered)
+	if nil != e {
+		return time.Time{}, e
 	}
+	return f.Parse(value)
+}
 
-	return time.Date(dt.year, dt.month, dt.day, dt.hour, dt.min, dt.sec, dt.nsec, dt.loc), nil
+// StrptimeAnyLocale tries the locales registered under names in turn, or
+// English followed by every other registered locale if no names are given,
+// and returns the result of the first one that parses value together with
+// that locale.
+func StrptimeAnyLocale(value string, format string, names ...string) (time.Time, *Locale, error) {
+	if len(names) == 0 {
+		names = append([]string{English.Name}, Locales()...)
+	}
+	candidates := make([]*Locale, 0, len(names))
+	seen := map[*Locale]bool{}
+	for _, name := range names {
+		l, ok := LookupLocale(name)
+		if !ok {
+			return time.Time{}, nil, errors.New("Unknown locale:" + name)
+		}
+		if !seen[l] {
+			seen[l] = true
+			candidates = append(candidates, l)
+		}
+	}
+	var err error
+	for _, l := range candidates {
+		t, e := StrptimeLocale(value, format, l)
+		if nil == e {
+			return t, l, nil
+		}
+		if nil == err {
+			err = e
+		}
+	}
+	return time.Time{}, nil, err
 }
@@ -16,19 +16,80 @@ Directives:
     %M - Minute as a decimal number [00,59]
     %p - Locale’s equivalent of either AM or PM
     %S - Second as a decimal number [00,61]
-    %U - Week number of the year
-    %w - Weekday as a decimal number
-    %W - Week number of the year
+    %U - Week number of the year (Sunday as the first day of the week) [00,53]
+    %w - Weekday as a decimal number [0(Sunday),6]
+    %W - Week number of the year (Monday as the first day of the week) [00,53]
     %x - Locale’s appropriate date representation
     %X - Locale’s appropriate time representation
     %y - Year without century as a decimal number [00,99]
     %Y - Year with century as a decimal number
+    %z - UTC offset in the form +HHMM or -HHMM
     %Z - Time zone name (no characters if no time zone exists)
+GNU/glibc extensions:
+    %N - Nanosecond as a decimal number [000000000,999999999]
+    %L - Millisecond as a decimal number [000,999]
+    %C - Century as a decimal number [00,99]
+    %D - Same as %m/%d/%y
+    %e - Day of the month as a space-padded decimal number [ 1,31]
+    %F - Same as %Y-%m-%d
+    %g - ISO 8601 week-based year without century [00,99]
+    %G - ISO 8601 week-based year with century
+    %h - Same as %b
+    %k - Hour (24-hour clock) as a space-padded decimal number [ 0,23]
+    %l - Hour (12-hour clock) as a space-padded decimal number [ 1,12]
+    %n - A newline character
+    %P - Like %p, but lower case
+    %r - Locale’s 12-hour clock time
+    %R - Same as %H:%M
+    %s - Seconds since the Epoch, 1970-01-01 00:00:00 UTC
+    %t - A tab character
+    %T - Same as %H:%M:%S
+    %u - ISO 8601 weekday as a decimal number [1(Monday),7]
+    %V - ISO 8601 week number of the year [01,53]
+    %+ - Date and time in date(1) format
+    %:z - UTC offset in the form +HH:MM; %::z adds seconds (+HH:MM:SS) and
+          %:::z uses the minimal precision needed (+05, +05:30)
+    %Ez - Like %z, but Z for UTC (and %E:z like %:z)
+Other extensions:
+    %J - Locale’s name of the era, BC for years before 1 and AD for the others
+    %i - Year of the era, where 1 BC is the year 0 of %Y
+    %q - Quarter of the year (1-4), as in GNU date
+    %v - Half of the year (1-2)
+    %K - Fiscal year of the locale; %:K without its century
+    %Q - Fiscal quarter of the locale (1-4)
+    %o - Fiscal period of the locale (01-12)
+Strptime accepts +HHMM, +HH:MM, +HH, +HH:MM:SS, Z, GMT+8 and UTC+08:00 for %z.
+Strptime reads second 60 as ParseOptions.LeapSecond says, and StrftimeTAI
+formats the leap seconds of a TAI time as second 60.
+Strptime accepts IANA names (America/New_York), legacy aliases (US/Eastern)
+and abbreviations (EST, CEST) for %Z; see ResolveZone for ambiguous ones.
+Flags, widths and modifiers:
+    Like GNU date, a directive may be written as %[flags][width][E|O]code.
+    %-d - Do not pad a numeric field (5)
+    %_d - Pad a numeric field with spaces ( 5)
+    %0e - Pad a numeric field with zeros (05)
+    %+Y - Pad with zeros and always give a sign, as ISO 8601 expanded years
+          (+2016, -0044, +12021); a width counts the sign
+    %^B - Convert the result to upper case (SEPTEMBER)
+    %#b - Swap the case of the result (SEP, am for %#p)
+    %6Y - Pad the result to at least 6 characters (002016, and " September" for %10B)
+    %3f, %9N - For %f, %N and %L the width is the number of fractional digits
+               (%3f is milliseconds); Strptime accepts 1 to 9 digits for all three
+    %EC - Era of the locale’s calendar (令和); %Ey the year of the era and %EY both (令和8年),
+          and %Ex and %Ec the date and time in eras; the Japanese locale has a calendar
+    %Od - Locale’s alternative digits; none of the bundled locales defines them
 Note that %c returns RFC1123 which is a bit different from what Python does
+Locales:
+    The names used by %a, %A, %b, %B, %p and %J, the patterns used by %c, %x
+    and %X, the fiscal year of %K, %Q and %o and the calendar of %EC and %Ey
+    come from a Locale. Strftime uses English; StrftimeLocale accepts any
+    Locale, such as the bundled French, German, Spanish, Japanese and
+    Chinese ones, or one found by name with LookupLocale.
+        str, err := timefmt.StrftimeLocale(time.Now(), "%A %d %B %Y", timefmt.French) // jeudi 22 septembre 2016
 */
 package timefmt
 
-var longDayNames = []string{
+var longDayNames = [7]string{
     "Sunday",
     "Monday",
     "Tuesday",
@@ -38,7 +99,7 @@ var longDayNames = []string{
     "Saturday",
 }
 
-var shortDayNames = []string{
+var shortDayNames = [7]string{
     "Sun",
     "Mon",
     "Tue",
@@ -48,8 +109,7 @@ var shortDayNames = []string{
     "Sat",
 }
 
-var shortMonthNames = []string{
-    "---",
+var shortMonthNames = [12]string{
     "Jan",
     "Feb",
     "Mar",
@@ -64,8 +124,7 @@ var shortMonthNames = []string{
     "Dec",
 }
 
-var longMonthNames = []string{
-    "---",
+var longMonthNames = [12]string{
     "January",
     "February",
     "March",
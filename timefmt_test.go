@@ -1,6 +1,10 @@
 package timefmt
 
 import (
+    "bytes"
+    "errors"
+    "strconv"
+    "strings"
     "testing"
     "time"
 )
@@ -44,6 +48,1137 @@ func TestStrftime(t *testing.T) {
     validate(tm, "%Y-%m-%dT%H:%M:%S %z", "2016-09-22T06:04:26 +0000")
 }
 
+func TestStrftimeGNU(t *testing.T) {
+    loc, _ := time.LoadLocation("UTC")
+    tm := time.Unix(1474524266, 321).In(loc)
+    format := "%C|%D|%e|%F|%g|%G|%h|%k|%l|%P|%r|%R|%s|%T|%u|%V"
+    result := "20|09/22/16|22|2016-09-22|16|2016|Sep| 6| 6|am|06:04:26 AM|06:04|1474524266|06:04:26|4|38"
+    if s, e := Strftime(tm, format); e != nil || s != result {
+        t.Errorf("Strftime(/%v/, '%s') should return '%s' but not (%s) (%s)\n", tm, format, result, e, s)
+    }
+    if s, e := Strftime(tm, "%+%n%t%-e"); e != nil || s != "Thu Sep 22 06:04:26 UTC 2016\n\t22" {
+        t.Errorf("Strftime(/%v/, '%%+%%n%%t%%-e') failed (%q) (%s)", tm, s, e)
+    }
+    early := time.Date(2016, 1, 3, 9, 0, 0, 0, loc)
+    if s, _ := Strftime(early, "%G-W%V-%u %e %k"); s != "2015-W53-7  3  9" {
+        t.Errorf("Strftime(/%v/, '%%G-W%%V-%%u %%e %%k') should return '2015-W53-7  3  9' but not (%q)", early, s)
+    }
+}
+
+func TestStrftimeFlags(t *testing.T) {
+    var validate = func(tm time.Time, format string, result string) {
+        if s, e := Strftime(tm, format); e != nil || s != result {
+            t.Errorf("Strftime(/%v/, '%s') should return '%s' but not (%s) (%s)\n", tm, format, result, e, s)
+        }
+    }
+    loc, _ := time.LoadLocation("UTC")
+    tm := time.Date(2016, 9, 5, 6, 4, 26, 0, loc)
+    validate(tm, "%_d|%-d|%0e|%^B|%#b|%#p|%#Z|%10B|%-10B", " 5|5|05|SEPTEMBER|SEP|am|utc| September|September")
+    validate(tm, "%6Y|%_6Y|%Ey|%Od|%_H|%3j|%_3H|%^a %-e", "002016|  2016|16|05| 6|249|  6|MON 5")
+    validate(tm, "%^c", "MON SEP 05 06:04:26 2016")
+    validate(time.Date(-44, 3, 15, 0, 0, 0, 0, loc), "%05Y|%_5Y", "-0044|  -44")
+    if s, e := StrftimeLocale(time.Date(2016, 2, 5, 0, 0, 0, 0, loc), "%^B %#a", French); e != nil || s != "FÉVRIER VEN." {
+        t.Errorf("StrftimeLocale('%%^B %%#a', French) should return 'FÉVRIER VEN.' but not (%s) (%s)", s, e)
+    }
+    if _, e := Strftime(tm, "%_5~"); e == nil {
+        t.Errorf("Strftime('%%_5~') should fail")
+    }
+    validate(tm, "100%", "100%")
+    validate(tm, "%Y%-", "2016-")
+}
+
+func TestStrptimeGNU(t *testing.T) {
+    var validate = func(val string, format string, result time.Time) {
+        if tm, e := Strptime(val, format); e != nil || tm != result {
+            t.Errorf("Strptime('%s', '%s') should return /%v/ but not (%v) (%s) \n", val, format, result, tm, e)
+        }
+    }
+    loc, _ := time.LoadLocation("UTC")
+    validate("2016-09-22 06:04:26", "%F %T", time.Date(2016, 9, 22, 6, 4, 26, 0, loc))
+    validate("09/22/16 06:04", "%D %R", time.Date(2016, 9, 22, 6, 4, 0, 0, loc))
+    validate("Sep  2 2016  6:04:26 pm", "%h %e %Y %l:%M:%S %P", time.Date(2016, 9, 2, 18, 4, 26, 0, loc))
+    validate("06:04:26 PM", "%r", time.Date(0, 0, 0, 18, 4, 26, 0, loc))
+    validate("1474524266", "%s", time.Date(2016, 9, 22, 6, 4, 26, 0, loc))
+    validate("19 16-09-22", "%C %y-%m-%d", time.Date(1916, 9, 22, 0, 0, 0, 0, loc))
+    validate("2016-09-22\n\t 7", "%F%n%k", time.Date(2016, 9, 22, 7, 0, 0, 0, loc))
+    validate("2016-09-  2", "%Y-%m-%_d", time.Date(2016, 9, 2, 0, 0, 0, 0, loc))
+
+    // %s is the same instant whichever side of %z or %Z it is on.
+    for _, c := range [][2]string{
+        {"1474524266 +0800", "%s %z"}, {"+0800 1474524266", "%z %s"},
+        {"1474524266 EST", "%s %Z"}, {"EST 1474524266", "%Z %s"},
+    } {
+        if tm, e := Strptime(c[0], c[1]); e != nil || tm.Unix() != 1474524266 {
+            t.Errorf("Strptime('%s', '%s') should return the instant 1474524266 but not (%v) (%v)", c[0], c[1], tm, e)
+        }
+    }
+}
+
+func TestFractionalSeconds(t *testing.T) {
+    loc, _ := time.LoadLocation("UTC")
+    tm := time.Date(2016, 9, 22, 6, 4, 26, 123456789, loc)
+    format := "%f|%3f|%9f|%1f|%N|%3N|%6N|%L|%6L|%12N"
+    result := "123456|123|123456789|1|123456789|123|123456|123|123456|123456789000"
+    if s, e := Strftime(tm, format); e != nil || s != result {
+        t.Errorf("Strftime(/%v/, '%s') should return '%s' but not (%s) (%s)", tm, format, result, e, s)
+    }
+    if s, _ := Strftime(time.Date(2016, 9, 22, 6, 4, 26, 7000, loc), "%3f %f %N"); s != "000 000007 000007000" {
+        t.Errorf("Strftime('%%3f %%f %%N') should keep leading zeros but not (%s)", s)
+    }
+    var validate = func(val string, format string, nsec int) {
+        result := time.Date(2016, 9, 22, 6, 4, 26, nsec, loc)
+        if r, e := Strptime(val, format); e != nil || r != result {
+            t.Errorf("Strptime('%s', '%s') should return /%v/ but not (%v) (%s)", val, format, result, r, e)
+        }
+    }
+    validate("2016-09-22T06:04:26.5", "%Y-%m-%dT%H:%M:%S.%f", 500000000)
+    validate("2016-09-22T06:04:26.123", "%Y-%m-%dT%H:%M:%S.%L", 123000000)
+    validate("2016-09-22T06:04:26.000321", "%Y-%m-%dT%H:%M:%S.%f", 321000)
+    validate("2016-09-22T06:04:26.123456789Z", "%Y-%m-%dT%H:%M:%S.%NZ", 123456789)
+    validate("2016-09-22T06:04:26.000000001", "%Y-%m-%dT%H:%M:%S.%3f", 1)
+
+    // Widths above 9 pad with zeros, which a strict parse reads back.
+    strict := ParseOptions{Strict: true}
+    f := MustCompile("%Y-%m-%dT%H:%M:%S.%12f")
+    if r, e := f.ParseWithOptions("2016-09-22T06:04:26.321000000000", strict); e != nil || r != time.Date(2016, 9, 22, 6, 4, 26, 321000000, loc) {
+        t.Errorf("ParseWithOptions('%%12f', Strict) should return 321ms but not (%v) (%s)", r, e)
+    }
+    if r, e := f.ParseWithOptions("2016-09-22T06:04:26.321000000001", strict); !errors.Is(e, ErrOutOfRange) {
+        t.Errorf("ParseWithOptions('%%12f', Strict) should fail with ErrOutOfRange but returned (%v) (%v)", r, e)
+    }
+}
+
+func TestUTCOffset(t *testing.T) {
+    var validate = func(tm time.Time, format string, result string) {
+        if s, e := Strftime(tm, format); e != nil || s != result {
+            t.Errorf("Strftime(/%v/, '%s') should return '%s' but not (%s) (%s)\n", tm, format, result, e, s)
+        }
+    }
+    utc := time.Date(2016, 9, 22, 6, 4, 26, 0, time.UTC)
+    validate(utc, "%z|%:z|%::z|%:::z|%Ez|%E:z", "+0000|+00:00|+00:00:00|+00|Z|Z")
+    ist := utc.In(time.FixedZone("IST", 5*3600+30*60))
+    validate(ist, "%z|%:z|%::z|%:::z|%Ez|%E:z", "+0530|+05:30|+05:30:00|+05:30|+0530|+05:30")
+    lmt := utc.In(time.FixedZone("LMT", -(1*3600 + 15)))
+    validate(lmt, "%z|%:z|%::z|%:::z", "-0100|-01:00|-01:00:15|-01:00:15")
+    if _, e := Strftime(utc, "%:d"); e == nil {
+        t.Errorf("Strftime('%%:d') should fail")
+    }
+
+    var cases = []struct {
+        value  string
+        offset int
+    }{
+        {"+0000", 0},
+        {"Z", 0},
+        {"UTC", 0},
+        {"+0530", 19800},
+        {"+05:30", 19800},
+        {"-08", -28800},
+        {"-01:00:15", -3615},
+        {"GMT+8", 28800},
+        {"GMT-3:30", -12600},
+        {"UTC+08:00", 28800},
+    }
+    for _, c := range cases {
+        val := "2016-09-22T06:04:26 " + c.value
+        r, e := Strptime(val, "%Y-%m-%dT%H:%M:%S %z")
+        if e != nil {
+            t.Errorf("Strptime('%s', '%%Y-%%m-%%dT%%H:%%M:%%S %%z') failed: %s", val, e)
+            continue
+        }
+        if _, o := r.Zone(); o != c.offset || r.Hour() != 6 {
+            t.Errorf("Strptime('%s') should have offset %d but not (%v)", val, c.offset, r)
+        }
+        if c.offset == 0 && r.Location() != time.UTC {
+            t.Errorf("Strptime('%s') should be in UTC but not (%v)", val, r.Location())
+        }
+    }
+    if _, e := Strptime("06:04 +2500", "%H:%M %z"); e == nil {
+        t.Errorf("Strptime('06:04 +2500') should fail")
+    }
+    // Digits are never left over.
+    for _, val := range []string{"06:04 GMT+12345", "06:04 +12345", "06:04 UTC+812"} {
+        if r, e := Strptime(val, "%H:%M %z"); e == nil {
+            t.Errorf("Strptime('%s', '%%H:%%M %%z') should fail but returned (%v)", val, r)
+        }
+    }
+    s, _ := Strftime(ist, "%Y-%m-%dT%H:%M:%S%:z")
+    if r, e := Strptime(s, "%Y-%m-%dT%H:%M:%S%:z"); e != nil || !r.Equal(ist) {
+        t.Errorf("Strptime('%s') should round-trip to /%v/ but not (%v) (%s)", s, ist, r, e)
+    }
+}
+
+func TestStrptimeWithOptions(t *testing.T) {
+    var validate = func(val string, format string, opts ParseOptions, result time.Time) {
+        tm, e := StrptimeWithOptions(val, format, opts)
+        if e != nil || !tm.Equal(result) || tm.Location().String() != result.Location().String() {
+            t.Errorf("StrptimeWithOptions('%s', '%s') should return /%v/ but not (%v) (%s)", val, format, result, tm, e)
+        }
+    }
+    ny, _ := time.LoadLocation("America/New_York")
+    paris, _ := time.LoadLocation("Europe/Paris")
+    ref := time.Date(2016, 9, 22, 6, 4, 26, 321, ny)
+
+    validate("14:30", "%H:%M", ParseOptions{}, time.Date(0, 0, 0, 14, 30, 0, 0, time.UTC))
+    validate("2016-09-22 14:30", "%Y-%m-%d %H:%M", ParseOptions{Location: ny}, time.Date(2016, 9, 22, 14, 30, 0, 0, ny))
+    validate("14:30", "%H:%M", ParseOptions{Location: ny, Reference: ref}, time.Date(2016, 9, 22, 14, 30, 0, 0, ny))
+    validate("30:15", "%M:%S", ParseOptions{Reference: ref}, time.Date(2016, 9, 22, 6, 30, 15, 0, time.UTC))
+    validate("2017-02", "%Y-%m", ParseOptions{Reference: time.Date(2016, 1, 31, 0, 0, 0, 0, time.UTC)}, time.Date(2017, 2, 28, 0, 0, 0, 0, time.UTC))
+    validate("Dec", "%b", ParseOptions{Location: ny, Reference: ref}, time.Date(2016, 12, 22, 6, 4, 26, 321, ny))
+    // Reference is read in Location.
+    validate("14:30", "%H:%M", ParseOptions{Location: paris, Reference: ref}, time.Date(2016, 9, 22, 14, 30, 0, 0, paris))
+
+    // A parsed zone wins, but the result stays in Location when they agree.
+    validate("2016-09-22 14:30 +0900", "%Y-%m-%d %H:%M %z", ParseOptions{Location: ny}, time.Date(2016, 9, 22, 14, 30, 0, 0, time.FixedZone("", 9*3600)))
+    validate("2016-09-22 14:30 -0400", "%Y-%m-%d %H:%M %z", ParseOptions{Location: ny}, time.Date(2016, 9, 22, 14, 30, 0, 0, ny))
+    validate("2016-09-22 14:30 EDT", "%Y-%m-%d %H:%M %Z", ParseOptions{Location: ny}, time.Date(2016, 9, 22, 14, 30, 0, 0, ny))
+    validate("2016-09-22 14:30 AST", "%Y-%m-%d %H:%M %Z", ParseOptions{Location: ny}, time.Date(2016, 9, 22, 14, 30, 0, 0, time.FixedZone("AST", -4*3600)))
+    validate("1474524266", "%s", ParseOptions{Location: paris}, time.Unix(1474524266, 0).In(paris))
+
+    f := MustCompile("%d %H:%M")
+    if tm, e := f.ParseWithOptions("23 08:15", ParseOptions{Location: ny, Reference: ref}); e != nil || tm != time.Date(2016, 9, 23, 8, 15, 0, 0, ny) {
+        t.Errorf("Format.ParseWithOptions('23 08:15') failed (%v) (%s)", tm, e)
+    }
+}
+
+func TestCenturyRule(t *testing.T) {
+    var validate = func(val string, format string, opts ParseOptions, year int) {
+        if tm, e := StrptimeWithOptions(val, format, opts); e != nil || tm.Year() != year {
+            t.Errorf("StrptimeWithOptions('%s', '%s') should be in %d but not (%v) (%s)", val, format, year, tm, e)
+        }
+    }
+    ref := time.Date(2016, 9, 22, 0, 0, 0, 0, time.UTC)
+    validate("69-01-01", "%y-%m-%d", ParseOptions{}, 2069)
+    validate("70-01-01", "%y-%m-%d", ParseOptions{}, 1970)
+    validate("49-01-01", "%y-%m-%d", ParseOptions{Century: PivotYear(1950)}, 2049)
+    validate("50-01-01", "%y-%m-%d", ParseOptions{Century: PivotYear(1950)}, 1950)
+    validate("65-01-01", "%y-%m-%d", ParseOptions{Century: SlidingWindow(50), Reference: ref}, 2065)
+    validate("66-01-01", "%y-%m-%d", ParseOptions{Century: SlidingWindow(50), Reference: ref}, 1966)
+    validate("16-01-01", "%y-%m-%d", ParseOptions{Century: PreferPast, Reference: ref}, 2016)
+    validate("17-01-01", "%y-%m-%d", ParseOptions{Century: PreferPast, Reference: ref}, 1917)
+    validate("15-01-01", "%y-%m-%d", ParseOptions{Century: PreferFuture, Reference: ref}, 2115)
+    validate("16-01-01", "%y-%m-%d", ParseOptions{Century: PreferFuture, Reference: ref}, 2016)
+    validate("19 17-01-01", "%C %y-%m-%d", ParseOptions{Century: PreferFuture, Reference: ref}, 1917)
+    validate("95-W01-1", "%g-W%V-%u", ParseOptions{Century: PreferFuture, Reference: ref}, 2095)
+    validate("55-01-01", "%y-%m-%d", ParseOptions{Century: PivotYear(-50)}, -45)
+
+    f := MustCompile("%d/%m/%y").WithOptions(ParseOptions{Century: PreferPast})
+    if tm, e := f.Parse("22/09/99"); e != nil || tm.Year() != 1999 {
+        t.Errorf("Format.WithOptions(PreferPast).Parse('22/09/99') should be in 1999 but not (%v) (%s)", tm, e)
+    }
+    if tm, e := f.ParseWithOptions("22/09/99", ParseOptions{Century: PivotYear(2000)}); e != nil || tm.Year() != 2099 {
+        t.Errorf("Format.ParseWithOptions('22/09/99', PivotYear(2000)) should be in 2099 but not (%v) (%s)", tm, e)
+    }
+}
+
+func TestStrictParse(t *testing.T) {
+    strict := ParseOptions{Strict: true}
+    var validate = func(val string, format string, result time.Time) {
+        if tm, e := StrptimeWithOptions(val, format, strict); e != nil || tm != result {
+            t.Errorf("StrptimeWithOptions('%s', '%s', Strict) should return /%v/ but not (%v) (%s)", val, format, result, tm, e)
+        }
+    }
+    var invalidate = func(val string, format string) {
+        if tm, e := StrptimeWithOptions(val, format, strict); e == nil {
+            t.Errorf("StrptimeWithOptions('%s', '%s', Strict) should fail but returned (%v)", val, format, tm)
+        }
+    }
+    validate("2016-09-22", "%Y-%m-%d", time.Date(2016, 9, 22, 0, 0, 0, 0, time.UTC))
+    validate("2016-9-2", "%Y-%-m-%-d", time.Date(2016, 9, 2, 0, 0, 0, 0, time.UTC))
+    validate("Sep  2 2016  6:04", "%b %e %Y %k:%M", time.Date(2016, 9, 2, 6, 4, 0, 0, time.UTC))
+    validate("Sep 12 2016", "%b %_d %Y", time.Date(2016, 9, 12, 0, 0, 0, 0, time.UTC))
+    validate("2016-02-29", "%Y-%m-%d", time.Date(2016, 2, 29, 0, 0, 0, 0, time.UTC))
+    validate("06:04:26.321", "%H:%M:%S.%L", time.Date(0, 0, 0, 6, 4, 26, 321000000, time.UTC))
+    validate("12:04 PM", "%I:%M %p", time.Date(0, 0, 0, 12, 4, 0, 0, time.UTC))
+    validate("2015-W53-5", "%G-W%V-%u", time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC))
+    validate("02/29", "%m/%d", time.Date(0, 2, 29, 0, 0, 0, 0, time.UTC))
+
+    invalidate("garbage2016-09-22moregarbage", "%Y-%m-%d")
+    invalidate("2016-09-22 ", "%Y-%m-%d")
+    invalidate("2016-9-22", "%Y-%m-%d")
+    invalidate("16-09-22", "%Y-%m-%d")
+    invalidate("Sep 2 2016", "%b %e %Y")
+    invalidate("06:04:26.3210", "%H:%M:%S.%L")
+    invalidate("2016-02-30", "%Y-%m-%d")
+    invalidate("2015-02-29", "%Y-%m-%d")
+    invalidate("2016-13-01", "%Y-%m-%d")
+    invalidate("2016-00-01", "%Y-%m-%d")
+    invalidate("2016-01-00", "%Y-%m-%d")
+    invalidate("24:00", "%H:%M")
+    invalidate("23:60", "%H:%M")
+    invalidate("23:59:60", "%H:%M:%S")
+    invalidate("00:30 AM", "%I:%M %p")
+    invalidate("13:30 PM", "%I:%M %p")
+    invalidate("2016-W53-1", "%G-W%V-%u")
+    invalidate("2016 54", "%Y %U")
+
+    // The same values are accepted, and normalised, without Strict.
+    if tm, e := Strptime("garbage2016-02-30moregarbage", "%Y-%m-%d"); e != nil || tm != time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC) {
+        t.Errorf("Strptime('garbage2016-02-30moregarbage') should return 2016-03-01 but not (%v) (%s)", tm, e)
+    }
+    f := MustCompile("%d/%m/%Y").WithOptions(strict)
+    if _, e := f.Parse("31/04/2016"); e == nil {
+        t.Errorf("Format.WithOptions(Strict).Parse('31/04/2016') should fail")
+    }
+
+    // A leap day is checked against the year the Reference fills in.
+    ref := ParseOptions{Strict: true, Reference: time.Date(2015, 6, 1, 0, 0, 0, 0, time.UTC)}
+    if tm, e := StrptimeWithOptions("02-29", "%m-%d", ref); !errors.Is(e, ErrOutOfRange) {
+        t.Errorf("StrptimeWithOptions('02-29', '%%m-%%d', Strict, Reference 2015) should fail with ErrOutOfRange but returned (%v) (%v)", tm, e)
+    }
+    ref.Reference = time.Date(2016, 6, 1, 0, 0, 0, 0, time.UTC)
+    if tm, e := StrptimeWithOptions("02-29", "%m-%d", ref); e != nil || tm != time.Date(2016, 2, 29, 0, 0, 0, 0, time.UTC) {
+        t.Errorf("StrptimeWithOptions('02-29', '%%m-%%d', Strict, Reference 2016) should return 2016-02-29 but not (%v) (%v)", tm, e)
+    }
+}
+
+func TestErrors(t *testing.T) {
+    var validate = func(val string, format string, opts ParseOptions, target error, directive string, text string, formatOffset int, valueOffset int) {
+        _, e := StrptimeWithOptions(val, format, opts)
+        pe, ok := e.(*ParseError)
+        if !ok || !errors.Is(e, target) {
+            t.Errorf("StrptimeWithOptions('%s', '%s') should fail with %s but not (%v)", val, format, target, e)
+            return
+        }
+        if pe.Directive != directive || pe.Text != text || pe.FormatOffset != formatOffset || pe.ValueOffset != valueOffset {
+            t.Errorf("StrptimeWithOptions('%s', '%s') should blame %q at %d/%q at %d but not %q at %d/%q at %d", val, format,
+                directive, formatOffset, text, valueOffset, pe.Directive, pe.FormatOffset, pe.Text, pe.ValueOffset)
+        }
+    }
+    strict := ParseOptions{Strict: true}
+    validate("2016-13-01", "%Y-%m-%d", strict, ErrOutOfRange, "%m", "13", 3, 5)
+    validate("2016-02-30", "%Y-%m-%d", strict, ErrOutOfRange, "%d", "30", 6, 8)
+    validate("25:00", "%k:%M", strict, ErrOutOfRange, "%k", "25", 0, 0)
+    validate("2016-09-22 +2500", "%Y-%m-%d %z", ParseOptions{}, ErrOutOfRange, "%z", "+2500", 9, 11)
+    validate("2016-09-x2", "%Y-%m-%d", ParseOptions{}, ErrNoMatch, "%d", "x2", 6, 8)
+    validate("2016/09/22", "%Y-%m-%d", ParseOptions{}, ErrNoMatch, "-", "/09/22", 2, 4)
+    validate("2016-09-22 garbage", "%Y-%m-%d", strict, ErrNoMatch, "", " garbage", 8, 10)
+
+    _, e := Strptime("2016-9-2", "%Y-%~-%d")
+    if fe, ok := e.(*FormatError); !ok || !errors.Is(e, ErrUnknownDirective) || fe.Directive != "%~" || fe.Offset != 3 {
+        t.Errorf("Strptime with %%~ should fail with ErrUnknownDirective but not (%v)", e)
+    }
+    _, e = Strftime(time.Now(), "%Y-%~-%d")
+    if !errors.Is(e, ErrUnknownDirective) {
+        t.Errorf("Strftime with %%~ should fail with ErrUnknownDirective but not (%v)", e)
+    }
+    validate("Mon 2016-09-22", "%a %Y-%m-%d", ParseOptions{}, ErrInconsistent, "%a", "Mon", 0, 0)
+    validate("2016-09-22 265", "%Y-%m-%d %j", ParseOptions{}, ErrInconsistent, "%j", "265", 9, 11)
+
+    _, e = StrptimeWithOptions("2016-13-01", "%Y-%m-%d", strict)
+    result := "timefmt: parsing \"13\" as %m: field out of range\n" +
+        "    value:  2016-13-01\n" +
+        "                 ^\n" +
+        "    format: %Y-%m-%d\n" +
+        "               ^"
+    if e == nil || e.Error() != result {
+        t.Errorf("ParseError.Error() should be\n%s\nbut not\n%v", result, e)
+    }
+    if pe, ok := e.(*ParseError); !ok || pe.Expected != "[0-9]{2}" {
+        t.Errorf("ParseError.Expected should be [0-9]{2} but not (%v)", e)
+    }
+}
+
+func TestStrptimeLiterals(t *testing.T) {
+    var validate = func(val string, format string, result time.Time) {
+        if tm, e := Strptime(val, format); e != nil || tm != result {
+            t.Errorf("Strptime('%s', '%s') should return /%v/ but not (%v) (%s)", val, format, result, tm, e)
+        }
+    }
+    var invalidate = func(val string, format string) {
+        if tm, e := Strptime(val, format); e == nil {
+            t.Errorf("Strptime('%s', '%s') should fail but returned (%v)", val, format, tm)
+        }
+    }
+    // Literals are matched literally, not as regexp syntax.
+    validate("12.34", "%H.%M", time.Date(0, 0, 0, 12, 34, 0, 0, time.UTC))
+    invalidate("12x34", "%H.%M")
+    validate("(2016) [09] +22*", "(%Y) [%m] +%d*", time.Date(2016, 9, 22, 0, 0, 0, 0, time.UTC))
+    invalidate("2016 09 22", "(%Y) [%m] +%d*")
+    validate("^2016$|09", "^%Y$|%m", time.Date(2016, 9, 0, 0, 0, 0, 0, time.UTC))
+    validate("100% 2016", "100%% %Y", time.Date(2016, 0, 0, 0, 0, 0, 0, time.UTC))
+    invalidate("100 2016", "100%% %Y")
+
+    // Numbers give back digits to the directives after them.
+    validate("2016922", "%Y%m%d", time.Date(2016, 92, 2, 0, 0, 0, 0, time.UTC))
+    validate("20160922", "%Y%m%d", time.Date(2016, 9, 22, 0, 0, 0, 0, time.UTC))
+    validate("1234", "%H%M", time.Date(0, 0, 0, 12, 34, 0, 0, time.UTC))
+    validate("123", "%H%M", time.Date(0, 0, 0, 12, 3, 0, 0, time.UTC))
+    validate("123:", "%H%M:", time.Date(0, 0, 0, 12, 3, 0, 0, time.UTC))
+    // A split that failed once is not tried again, which would take 2^40
+    // tries here.
+    invalidate(strings.Repeat("12", 40)+"y", strings.Repeat("%-M", 40)+"x")
+    // Names are tried longest first.
+    if tm, e := StrptimeLocale("mars2016", "%b%Y", French); e != nil || tm != time.Date(2016, 3, 0, 0, 0, 0, 0, time.UTC) {
+        t.Errorf("StrptimeLocale('mars2016', '%%b%%Y', French) should be in March but not (%v) (%s)", tm, e)
+    }
+
+    // The value is searched for the format unless parsing is strict.
+    validate("on 2016-09-22, at 06:04", "%Y-%m-%d, at %H:%M", time.Date(2016, 9, 22, 6, 4, 0, 0, time.UTC))
+    validate("GMT+8 2016", "%z %Y", time.Date(2016, 0, 0, 0, 0, 0, 0, time.FixedZone("", 8*3600)))
+}
+
+func TestExtendedYears(t *testing.T) {
+    var validate = func(tm time.Time, format string, l *Locale, result string) {
+        if s, e := StrftimeLocale(tm, format, l); e != nil || s != result {
+            t.Errorf("StrftimeLocale(/%v/, '%s') should return '%s' but not '%s' (%s)", tm, format, result, s, e)
+        }
+        if r, e := StrptimeLocale(result, format, l); e != nil || r != tm {
+            t.Errorf("StrptimeLocale('%s', '%s') should return /%v/ but not (%v) (%s)", result, format, tm, r, e)
+        }
+    }
+    var date = func(year int) time.Time {
+        return time.Date(year, 3, 15, 0, 0, 0, 0, time.UTC)
+    }
+    validate(date(2016), "%+Y-%m-%d", English, "+2016-03-15")
+    validate(date(12021), "%+Y-%m-%d", English, "+12021-03-15")
+    validate(date(-44), "%+Y-%m-%d", English, "-0044-03-15")
+    validate(date(0), "%+Y-%m-%d", English, "+0000-03-15")
+    validate(date(-44), "%+7Y-%m-%d", English, "-000044-03-15")
+    validate(date(2016), "%+7Y-%m-%d", English, "+002016-03-15")
+    validate(date(12021), "%Y-%m-%d", English, "12021-03-15")
+    validate(date(2016), "%Y%m%d", English, "20160315")
+    validate(date(-44), "%Y-%m-%d", English, "-44-03-15")
+    for _, year := range []int{1, 9, 44, 800, 999} {
+        validate(date(year), "%Y-%m-%d", English, strconv.Itoa(year)+"-03-15")
+        validate(date(year), "%m/%d/%Y", English, "03/15/"+strconv.Itoa(year))
+    }
+    validate(date(-44), "%F", English, "-44-03-15")
+    validate(date(-44), "%y %C %Y %m %d", English, "56 -1 -44 03 15")
+    validate(date(-144), "%C%y-%m-%d", English, "-256-03-15")
+    validate(date(-12021), "%Y-%m-%d", English, "-12021-03-15")
+    validate(date(-43), "%d %b %i %J", English, "15 Mar 44 BC")
+    validate(date(2016), "%d %b %i %J", English, "15 Mar 2016 AD")
+    validate(date(0), "%m/%d/%i %J", English, "03/15/1 BC")
+    validate(date(-43), "%d %B %i %J", French, "15 mars 44 av. J.-C.")
+    validate(date(-43), "%J%i年%m月%d日", Japanese, "紀元前44年03月15日")
+
+    var parse = func(val string, format string, opts ParseOptions, result time.Time) {
+        if tm, e := StrptimeWithOptions(val, format, opts); e != nil || tm != result {
+            t.Errorf("StrptimeWithOptions('%s', '%s') should return /%v/ but not (%v) (%s)", val, format, result, tm, e)
+        }
+    }
+    var invalidate = func(val string, format string, opts ParseOptions, err error) {
+        if tm, e := StrptimeWithOptions(val, format, opts); !errors.Is(e, err) {
+            t.Errorf("StrptimeWithOptions('%s', '%s') should fail with %v but returned (%v) (%v)", val, format, err, tm, e)
+        }
+    }
+    strict := ParseOptions{Strict: true}
+    parse("44 BCE", "%i %J", ParseOptions{}, time.Date(-43, 0, 0, 0, 0, 0, 0, time.UTC))
+    parse("44 b.c.", "%i %J", ParseOptions{}, time.Date(-43, 0, 0, 0, 0, 0, 0, time.UTC))
+    parse("2016 CE", "%i %J", ParseOptions{}, time.Date(2016, 0, 0, 0, 0, 0, 0, time.UTC))
+    parse("-0044 BC", "%+Y %J", ParseOptions{}, time.Date(-44, 0, 0, 0, 0, 0, 0, time.UTC))
+    parse("2016", "%+Y", ParseOptions{}, time.Date(2016, 0, 0, 0, 0, 0, 0, time.UTC))
+    parse("+12021", "%+6Y", strict, time.Date(12021, 0, 0, 0, 0, 0, 0, time.UTC))
+    parse("12021-03-15", "%Y-%m-%d", strict, date(12021))
+    // Four digits are tried first, so only a whole match takes more.
+    parse("120210315", "%Y%m%d", strict, date(12021))
+    parse("120210315", "%Y%m%d", ParseOptions{}, time.Date(1202, 10, 31, 0, 0, 0, 0, time.UTC))
+    invalidate("2016", "%+6Y", strict, ErrNoMatch)
+    invalidate("+2016", "%Y", strict, ErrNoMatch)
+    parse("800", "%Y", ParseOptions{}, time.Date(800, 0, 0, 0, 0, 0, 0, time.UTC))
+    invalidate("800", "%Y", strict, ErrNoMatch)
+    // The sign is not skipped to match from the digits after it.
+    parse("-0044-03-15", "%Y-%m-%d", ParseOptions{}, date(-44))
+    invalidate("0 BC", "%i %J", strict, ErrOutOfRange)
+    invalidate("2016 BC", "%Y %J", ParseOptions{}, ErrInconsistent)
+    invalidate("44 BC -0044", "%i %J %+Y", ParseOptions{}, ErrInconsistent)
+}
+
+func TestLeapSeconds(t *testing.T) {
+    // 2016-12-31 23:59:60 UTC, when TAI was 36 seconds ahead of UTC.
+    leap := time.Unix(1483228800+36, 500000000).UTC()
+    for _, c := range []struct {
+        tai time.Time
+        result string
+        leap bool
+    }{
+        {leap.Add(-time.Second), "2016-12-31 23:59:59.500", false},
+        {leap, "2016-12-31 23:59:60.500", true},
+        {leap.Add(time.Second), "2017-01-01 00:00:00.500", false},
+        {time.Date(2020, 1, 1, 0, 0, 37, 0, time.UTC), "2020-01-01 00:00:00.000", false},
+        {time.Date(1970, 1, 1, 0, 0, 10, 0, time.UTC), "1970-01-01 00:00:00.000", false},
+    } {
+        if s, e := StrftimeTAI(c.tai, "%F %T.%L"); e != nil || s != c.result {
+            t.Errorf("StrftimeTAI(/%v/) should return '%s' but not '%s' (%s)", c.tai, c.result, s, e)
+        }
+        if _, l := FromTAI(c.tai); l != c.leap {
+            t.Errorf("FromTAI(/%v/) should report a leap second: %v", c.tai, c.leap)
+        }
+    }
+    if s := MustCompile("%H:%M:%S %Z").FormatTAI(leap.In(time.FixedZone("JST", 9*3600))); s != "08:59:60 JST" {
+        t.Errorf("FormatTAI(/%v/) in JST should return '08:59:60 JST' but not '%s'", leap, s)
+    }
+    if s := MustCompile("%c").FormatTAI(leap); s != "Sat Dec 31 23:59:60 2016" {
+        t.Errorf("FormatTAI(/%v/) with %%c should return 'Sat Dec 31 23:59:60 2016' but not '%s'", leap, s)
+    }
+    if l := LeapSeconds(); len(l) != 27 || l[0] != time.Date(1972, 6, 30, 23, 59, 59, 0, time.UTC) || l[26] != time.Date(2016, 12, 31, 23, 59, 59, 0, time.UTC) {
+        t.Errorf("LeapSeconds() should run from 1972-06-30 to 2016-12-31 but not (%v)", l)
+    }
+
+    var validate = func(opts ParseOptions, result time.Time, err error) {
+        tm, e := StrptimeWithOptions("2016-12-31 23:59:60", "%Y-%m-%d %H:%M:%S", opts)
+        if (err == nil && e != nil) || (err != nil && !errors.Is(e, err)) || (err != ErrOutOfRange && tm != result) {
+            t.Errorf("StrptimeWithOptions('2016-12-31 23:59:60', %+v) should return /%v/ (%v) but not /%v/ (%v)", opts, result, err, tm, e)
+        }
+    }
+    next := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+    clamped := time.Date(2016, 12, 31, 23, 59, 59, 999999999, time.UTC)
+    validate(ParseOptions{}, next, nil)
+    validate(ParseOptions{Strict: true}, time.Time{}, ErrOutOfRange)
+    validate(ParseOptions{LeapSecond: LeapSecondReject}, time.Time{}, ErrOutOfRange)
+    validate(ParseOptions{LeapSecond: LeapSecondClamp}, clamped, nil)
+    validate(ParseOptions{LeapSecond: LeapSecondClamp, Strict: true}, clamped, nil)
+    validate(ParseOptions{LeapSecond: LeapSecondReport}, next, ErrLeapSecond)
+    validate(ParseOptions{LeapSecond: LeapSecondReport, Strict: true}, next, ErrLeapSecond)
+    if _, e := StrptimeWithOptions("23:59:61", "%H:%M:%S", ParseOptions{LeapSecond: LeapSecondClamp, Strict: true}); !errors.Is(e, ErrOutOfRange) {
+        t.Errorf("StrptimeWithOptions('23:59:61') should fail with ErrOutOfRange but not (%v)", e)
+    }
+}
+
+func TestParseComponents(t *testing.T) {
+    var validate = func(val string, format string, fields Field, result Components) {
+        c, e := ParseComponents(val, format)
+        result.Fields = fields
+        if e != nil || c != result {
+            t.Errorf("ParseComponents('%s', '%s') should return %+v but not %+v (%s)", val, format, result, c, e)
+        }
+    }
+    validate("06:04", "%H:%M", FieldHour|FieldMinute, Components{Hour: 6, Minute: 4})
+    validate("2016-09", "%Y-%m", FieldYear|FieldMonth, Components{Year: 2016, Month: 9})
+    validate("06:04 PM", "%I:%M %p", FieldHour|FieldMinute, Components{Hour: 18, Minute: 4})
+    validate("2016 266", "%Y %j", FieldDate|FieldYearDay, Components{Year: 2016, Month: 9, Day: 22, YearDay: 266})
+    validate("Thu 2016-09-22", "%a %F", FieldDate|FieldWeekday, Components{Year: 2016, Month: 9, Day: 22, Weekday: time.Thursday})
+    validate("23:59:60.5", "%T.%f", FieldClock, Components{Hour: 23, Minute: 59, Second: 60, Nanosecond: 500000000})
+    validate("2016-09-22 +0000", "%F %z", FieldDate|FieldZone, Components{Year: 2016, Month: 9, Day: 22, Location: time.UTC})
+
+    c, e := ParseComponents("2016-09-22 06:04:26", "%F %T")
+    if d, ok := c.Date(); e != nil || !ok || d != (Date{2016, 9, 22}) || d.String() != "2016-09-22" {
+        t.Errorf("Components.Date() should return 2016-09-22 but not %v (%v) (%s)", d, ok, e)
+    }
+    if tod, ok := c.TimeOfDay(); !ok || tod != (TimeOfDay{6, 4, 26, 0}) || tod.String() != "06:04:26" {
+        t.Errorf("Components.TimeOfDay() should return 06:04:26 but not %v (%v)", tod, ok)
+    }
+    if dt, ok := c.DateTime(); !ok || dt.String() != "2016-09-22T06:04:26" {
+        t.Errorf("Components.DateTime() should return 2016-09-22T06:04:26 but not %v (%v)", dt, ok)
+    }
+    loc := time.FixedZone("X", 3600)
+    if tm := c.In(loc); tm != time.Date(2016, 9, 22, 6, 4, 26, 0, loc) {
+        t.Errorf("Components.In() should return 2016-09-22 06:04:26 +0100 but not %v", tm)
+    }
+    c, _ = ParseComponents("06:04", "%H:%M")
+    if _, ok := c.Date(); ok {
+        t.Errorf("Components.Date() of '06:04' should not be ok")
+    }
+    if tm := c.In(time.UTC); tm != time.Date(0, 1, 1, 6, 4, 0, 0, time.UTC) {
+        t.Errorf("Components.In() of '06:04' should return 0000-01-01 06:04 but not %v", tm)
+    }
+    c, _ = MustCompile("%F").WithOptions(ParseOptions{Strict: true}).ParseComponents("2016-09-22")
+    if d, ok := c.Date(); !ok || d != (Date{2016, 9, 22}) {
+        t.Errorf("Format.ParseComponents('2016-09-22') should return 2016-09-22 but not %v", d)
+    }
+    if _, e := MustCompile("%F").WithOptions(ParseOptions{Strict: true}).ParseComponents("2016-09-22x"); !errors.Is(e, ErrNoMatch) {
+        t.Errorf("Format.ParseComponents('2016-09-22x') should fail with ErrNoMatch but not (%v)", e)
+    }
+}
+
+func TestCivil(t *testing.T) {
+    var validate = func(s string, e error, result string) {
+        if e != nil || s != result {
+            t.Errorf("Strftime should return '%s' but not '%s' (%s)", result, s, e)
+        }
+    }
+    d := Date{2016, 9, 22}
+    s, e := d.Strftime("%a %F|%z|%Z|")
+    validate(s, e, "Thu 2016-09-22|||")
+    tod := TimeOfDay{Hour: 18, Minute: 4, Second: 26, Nanosecond: 321000000}
+    s, e = tod.Strftime("%I:%M:%S.%L %p|%z")
+    validate(s, e, "06:04:26.321 PM|")
+    validate(tod.String(), nil, "18:04:26.321")
+    dt := d.At(tod)
+    s, e = dt.Strftime("%c %z%Z")
+    validate(s, e, "Thu Sep 22 18:04:26 2016 ")
+    validate(dt.String(), nil, "2016-09-22T18:04:26.321")
+    s, e = DateTime{Date{2016, 12, 31}, TimeOfDay{23, 59, 60, 0}}.Strftime("%F %T")
+    validate(s, e, "2016-12-31 23:59:60")
+
+    loc := time.FixedZone("X", -3600)
+    tm := time.Date(2016, 9, 22, 18, 4, 26, 321000000, loc)
+    if DateOf(tm) != d || TimeOfDayOf(tm) != tod || DateTimeOf(tm) != dt {
+        t.Errorf("DateOf, TimeOfDayOf and DateTimeOf(/%v/) should return %v", tm, dt)
+    }
+    if dt.In(loc) != tm || d.In(loc) != time.Date(2016, 9, 22, 0, 0, 0, 0, loc) {
+        t.Errorf("DateTime.In and Date.In should return /%v/ but not /%v/", tm, dt.In(loc))
+    }
+    if !d.IsValid() || (Date{2016, 2, 30}).IsValid() || !(TimeOfDay{23, 59, 60, 0}).IsValid() || (TimeOfDay{24, 0, 0, 0}).IsValid() || !dt.IsValid() {
+        t.Errorf("IsValid() is wrong")
+    }
+}
+
+// dbDate and dbTimestamp stand for the types of a database driver.
+type dbDate struct {
+    y, m, d int
+}
+
+func (v dbDate) Date() (int, time.Month, int) {
+    return v.y, time.Month(v.m), v.d
+}
+
+type dbTimestamp struct {
+    dbDate
+    hh, mm, ss int
+}
+
+func (v dbTimestamp) Clock() (int, int, int) {
+    return v.hh, v.mm, v.ss
+}
+
+func (v dbTimestamp) Zone() (string, int) {
+    return "CET", 3600
+}
+
+func TestStrftimeValue(t *testing.T) {
+    var validate = func(v Timelike, format string, result string) {
+        if s, e := StrftimeValue(v, format); e != nil || s != result {
+            t.Errorf("StrftimeValue(%v, '%s') should return '%s' but not '%s' (%s)", v, format, result, s, e)
+        }
+    }
+    var invalidate = func(v Timelike, format string, directive string) {
+        s, e := StrftimeValue(v, format)
+        if fe, ok := e.(*FormatError); !ok || !errors.Is(e, ErrMissingField) || fe.Directive != directive {
+            t.Errorf("StrftimeValue(%v, '%s') should fail with ErrMissingField at %s but returned '%s' (%v)", v, format, directive, s, e)
+        }
+    }
+    tm := time.Date(2016, 9, 22, 6, 4, 26, 321000000, time.FixedZone("CEST", 7200))
+    validate(tm, "%F %T.%L %z %Z", "2016-09-22 06:04:26.321 +0200 CEST")
+    validate(Date{2016, 9, 22}, "%a %F %j %z", "Thu 2016-09-22 266 ")
+    validate(TimeOfDay{18, 4, 26, 0}, "%r %Z", "06:04:26 PM ")
+    validate(DateTime{Date{2016, 9, 22}, TimeOfDay{6, 4, 26, 0}}, "%c %s", "Thu Sep 22 06:04:26 2016 1474524266")
+    validate(TimeOfDay{23, 59, 60, 0}, "%T", "23:59:60")
+    validate(UnixSeconds(1474524266), "%F %T %z", "2016-09-22 06:04:26 +0000")
+    validate(UnixMillis(-1500), "%F %T.%L", "1969-12-31 23:59:58.500")
+    validate(dbDate{2016, 9, 22}, "%d/%m/%Y %z", "22/09/2016 ")
+    validate(dbTimestamp{dbDate{2016, 9, 22}, 6, 4, 26}, "%F %T.%L %z %Z", "2016-09-22 06:04:26.000 +0100 CET")
+
+    invalidate(Date{2016, 9, 22}, "%F %H:%M", "%H")
+    invalidate(Date{2016, 9, 22}, "%F %T", "%T")
+    invalidate(Date{2016, 9, 22}, "%c", "%c")
+    invalidate(Date{2016, 9, 22}, "%s", "%s")
+    invalidate(TimeOfDay{6, 4, 26, 0}, "%T %x", "%x")
+    invalidate(TimeOfDay{6, 4, 26, 0}, "%-d", "%-d")
+    invalidate(dbDate{2016, 9, 22}, "%F %p", "%p")
+
+    validate(int64(1474524266), "%F %T %z", "2016-09-22 06:04:26 +0000")
+    validate(1474524266, "%s", "1474524266")
+    pointer := time.Date(2016, 9, 22, 6, 4, 26, 0, time.UTC)
+    validate(&pointer, "%F %T %z", "2016-09-22 06:04:26 +0000")
+    for _, v := range []Timelike{"x", 1.5, struct{}{}, uint64(1<<63 + 5), (*time.Time)(nil), (*dbDate)(nil), nil} {
+        if s, e := StrftimeValue(v, "%%"); !errors.Is(e, ErrUnsupportedValue) {
+            t.Errorf("StrftimeValue(%v, '%%%%') should fail with ErrUnsupportedValue but returned '%s' (%v)", v, s, e)
+        }
+    }
+}
+
+func TestFiscalYear(t *testing.T) {
+    federal, japan, retail := *English, *Japanese, *English
+    federal.FiscalYear = FiscalYear{Start: time.October}
+    japan.FiscalYear = FiscalYear{Start: time.April, NamedByStart: true}
+    // The 4-5-4 calendar of the National Retail Federation.
+    retail.FiscalYear = FiscalYear{Start: time.February, NamedByStart: true, Pattern: Weeks454, LastWeekday: time.Saturday}
+
+    var validate = func(tm time.Time, format string, l *Locale, result string) {
+        if s, e := StrftimeLocale(tm, format, l); e != nil || s != result {
+            t.Errorf("StrftimeLocale(/%v/, '%s') should return '%s' but not '%s' (%s)", tm, format, result, s, e)
+        }
+    }
+    validate(time.Date(2016, 9, 22, 0, 0, 0, 0, time.UTC), "%Y-Q%q H%v FY%K Q%Q P%o", English, "2016-Q3 H2 FY2016 Q3 P09")
+    validate(time.Date(2016, 9, 22, 0, 0, 0, 0, time.UTC), "FY%:K Q%Q P%o", &federal, "FY16 Q4 P12")
+    validate(time.Date(2016, 11, 15, 0, 0, 0, 0, time.UTC), "FY%:K Q%Q P%-o", &federal, "FY17 Q1 P2")
+    validate(time.Date(2017, 3, 31, 0, 0, 0, 0, time.UTC), "%K年度 Q%Q", &japan, "2016年度 Q4")
+    validate(time.Date(2017, 4, 1, 0, 0, 0, 0, time.UTC), "%K年度 Q%Q", &japan, "2017年度 Q1")
+    validate(time.Date(2017, 1, 28, 0, 0, 0, 0, time.UTC), "%K P%o", &retail, "2016 P12")
+    validate(time.Date(2017, 1, 29, 0, 0, 0, 0, time.UTC), "%K P%o", &retail, "2017 P01")
+    validate(time.Date(2017, 2, 26, 0, 0, 0, 0, time.UTC), "%K P%o", &retail, "2017 P02")
+    validate(time.Date(2017, 4, 1, 0, 0, 0, 0, time.UTC), "%K P%o", &retail, "2017 P02")
+    validate(time.Date(2018, 2, 3, 0, 0, 0, 0, time.UTC), "%K Q%Q P%o", &retail, "2017 Q4 P12")
+    validate(time.Date(2018, 2, 4, 0, 0, 0, 0, time.UTC), "%K P%o", &retail, "2018 P01")
+
+    for _, c := range []struct {
+        fy FiscalYear
+        year, period int
+        start time.Time
+    }{
+        {federal.FiscalYear, 2017, 4, time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)},
+        {japan.FiscalYear, 2016, 12, time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC)},
+        {retail.FiscalYear, 2017, 1, time.Date(2017, 1, 29, 0, 0, 0, 0, time.UTC)},
+        {retail.FiscalYear, 2017, 12, time.Date(2017, 12, 31, 0, 0, 0, 0, time.UTC)},
+        {retail.FiscalYear, 2017, 13, time.Date(2018, 2, 4, 0, 0, 0, 0, time.UTC)},
+    } {
+        if s := c.fy.PeriodStart(c.year, c.period, time.UTC); s != c.start {
+            t.Errorf("%+v.PeriodStart(%d, %d) should return /%v/ but not /%v/", c.fy, c.year, c.period, c.start, s)
+        }
+        if year, period := c.fy.Period(c.start); c.period <= 12 && (year != c.year || period != c.period) {
+            t.Errorf("%+v.Period(/%v/) should return %d %d but not %d %d", c.fy, c.start, c.year, c.period, year, period)
+        }
+    }
+
+    var parse = func(val string, format string, l *Locale, result time.Time) {
+        if tm, e := StrptimeLocale(val, format, l); e != nil || tm != result {
+            t.Errorf("StrptimeLocale('%s', '%s') should return /%v/ but not (%v) (%v)", val, format, result, tm, e)
+        }
+    }
+    parse("2016-Q3", "%Y-Q%q", English, time.Date(2016, 7, 1, 0, 0, 0, 0, time.UTC))
+    parse("H2 2016", "H%v %Y", English, time.Date(2016, 7, 1, 0, 0, 0, 0, time.UTC))
+    parse("2016-09-22 Q3", "%F Q%q", English, time.Date(2016, 9, 22, 0, 0, 0, 0, time.UTC))
+    parse("FY17 P04", "FY%:K P%o", &federal, time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC))
+    parse("FY2017 Q2", "FY%K Q%Q", &federal, time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC))
+    parse("FY17 P04 15", "FY%:K P%o %d", &federal, time.Date(2017, 1, 15, 0, 0, 0, 0, time.UTC))
+    parse("2017 P12", "%K P%o", &retail, time.Date(2017, 12, 31, 0, 0, 0, 0, time.UTC))
+    parse("2016-11-15 FY17 Q1", "%F FY%:K Q%Q", &federal, time.Date(2016, 11, 15, 0, 0, 0, 0, time.UTC))
+
+    var inconsistent = func(val string, format string, l *Locale) {
+        if tm, e := StrptimeLocale(val, format, l); !errors.Is(e, ErrInconsistent) {
+            t.Errorf("StrptimeLocale('%s', '%s') should fail with ErrInconsistent but returned (%v) (%v)", val, format, tm, e)
+        }
+    }
+    inconsistent("2016-09-22 Q2", "%F Q%q", English)
+    inconsistent("2016-09 H1", "%Y-%m H%v", English)
+    inconsistent("2016-11-15 FY16", "%F FY%:K", &federal)
+    inconsistent("FY17 Q2 P07", "FY%:K Q%Q P%o", &federal)
+    inconsistent("2016-12 FY17 P04", "%Y-%m FY%:K P%o", &federal)
+    if tm, e := StrptimeLocale("2016-Q5", "%Y-Q%q", English); !errors.Is(e, ErrOutOfRange) {
+        t.Errorf("StrptimeLocale('2016-Q5') should fail with ErrOutOfRange but returned (%v) (%v)", tm, e)
+    }
+}
+
+func TestCalendar(t *testing.T) {
+    initials := *Japanese
+    initials.Calendar = JapaneseCalendar.WithName(1)
+
+    var validate = func(tm time.Time, format string, l *Locale, result string) {
+        if s, e := StrftimeLocale(tm, format, l); e != nil || s != result {
+            t.Errorf("StrftimeLocale(/%v/, '%s') should return '%s' but not '%s' (%s)", tm, format, result, s, e)
+        }
+    }
+    validate(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), "%EY%-m月%-d日", Japanese, "令和8年10月18日")
+    validate(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), "%Ex", Japanese, "令和8年10月18日")
+    validate(time.Date(2026, 10, 18, 6, 4, 26, 0, time.UTC), "%Ec", Japanese, "令和8年10月18日 06時04分26秒")
+    validate(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), "%EC%Ey.%m.%d", &initials, "R8.10.18")
+    validate(time.Date(2019, 4, 30, 0, 0, 0, 0, time.UTC), "%EY", Japanese, "平成31年")
+    validate(time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC), "%EY", Japanese, "令和元年")
+    validate(time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC), "%EC%Ey", Japanese, "令和1")
+    validate(time.Date(1989, 1, 7, 0, 0, 0, 0, time.UTC), "%EY", &initials, "S64年")
+    validate(time.Date(1868, 10, 22, 0, 0, 0, 0, time.UTC), "%EY %EC %Ey", Japanese, "1868 18 68")
+    validate(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), "%EY %Ex", English, "2026 10/18/26")
+    validate(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), "%x", Japanese, "2026年10月18日")
+
+    var parse = func(val string, format string, l *Locale, result time.Time) {
+        if tm, e := StrptimeLocale(val, format, l); e != nil || tm != result {
+            t.Errorf("StrptimeLocale('%s', '%s') should return /%v/ but not (%v) (%v)", val, format, result, tm, e)
+        }
+    }
+    parse("令和元年5月1日", "%EY%m月%d日", Japanese, time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC))
+    parse("令和8年10月18日", "%Ex", Japanese, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC))
+    parse("平成31年4月30日", "%Ex", Japanese, time.Date(2019, 4, 30, 0, 0, 0, 0, time.UTC))
+    parse("R8.10.18", "%EC%Ey.%m.%d", Japanese, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC))
+    parse("heisei 31-01-08", "%EC %Ey-%m-%d", Japanese, time.Date(2019, 1, 8, 0, 0, 0, 0, time.UTC))
+    parse("令和 2026-10-18", "%EC %F", Japanese, time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC))
+
+    var fail = func(val string, format string, l *Locale, opts ParseOptions, err error) {
+        f := MustCompile(format).WithLocale(l)
+        if tm, e := f.ParseWithOptions(val, opts); !errors.Is(e, err) {
+            t.Errorf("ParseWithOptions('%s', '%s') should fail with %v but returned (%v) (%v)", val, format, err, tm, e)
+        }
+    }
+    fail("8年10月18日", "%Ey年%m月%d日", Japanese, ParseOptions{}, ErrMissingField)
+    fail("2026 令和7年", "%Y %EY", Japanese, ParseOptions{}, ErrInconsistent)
+    fail("平成 2026-10-18", "%EC %F", Japanese, ParseOptions{}, ErrInconsistent)
+    fail("令和1年04月30日", "%Ex", Japanese, ParseOptions{Strict: true}, ErrOutOfRange)
+    parse("令和1年4月30日", "%Ex", Japanese, time.Date(2019, 4, 30, 0, 0, 0, 0, time.UTC))
+
+    // Era patterns that would expand forever are errors.
+    for _, pattern := range []string{"%EY", "%_EY", "%-EY", "%4EY", "%Ex", "%Ec", "%c", "%Ey%X"} {
+        l := *Japanese
+        l.EraYearFormat = pattern
+        if s, e := StrftimeLocale(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC), "%EY", &l); e == nil {
+            t.Errorf("StrftimeLocale('%%EY') with EraYearFormat '%s' should fail but returned '%s'", pattern, s)
+        }
+        if tm, e := StrptimeLocale("令和8年", "%EY", &l); e == nil {
+            t.Errorf("StrptimeLocale('%%EY') with EraYearFormat '%s' should fail but returned (%v)", pattern, tm)
+        }
+        // A compiled Format fails the same way rather than expanding the pattern forever.
+        if s, e := MustCompile("%EY").WithLocale(&l).Strftime(time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)); e == nil {
+            t.Errorf("Format('%%EY').Strftime with EraYearFormat '%s' should fail but returned '%s'", pattern, s)
+        }
+    }
+}
+
+func TestRegisterDirective(t *testing.T) {
+    // A shift code: A from 06:00, B from 14:00 and C from 22:00.
+    shift := Directive{
+        Format: func(tm time.Time) string {
+            return string(rune('A' + ((tm.Hour()+2)/8+2)%3))
+        },
+        ParsePattern: "[ABCabc]",
+        Parse: func(text string, c *Components) error {
+            if !c.Has(FieldHour) {
+                c.Hour, c.Fields = 6+8*int(text[0]&^0x20-'A'), c.Fields|FieldHour
+            }
+            return nil
+        },
+    }
+    if e := RegisterDirective('!', shift); e != nil {
+        t.Fatalf("RegisterDirective('!') failed: %s", e)
+    }
+    for _, r := range []rune{'!', 'Y', 'n'} {
+        if e := RegisterDirective(r, shift); !errors.Is(e, ErrDirectiveInUse) {
+            t.Errorf("RegisterDirective('%c') should fail with ErrDirectiveInUse but not (%v)", r, e)
+        }
+    }
+    for _, r := range []rune{'-', '0', 'E', ':', '%', ' ', 'é'} {
+        if e := RegisterDirective(r, shift); e == nil || errors.Is(e, ErrDirectiveInUse) {
+            t.Errorf("RegisterDirective('%c') should fail as invalid but not (%v)", r, e)
+        }
+    }
+    if e := RegisterDirective('&', Directive{Format: shift.Format, ParsePattern: "("}); e == nil {
+        t.Errorf("RegisterDirective('&') should fail on an invalid ParsePattern")
+    }
+
+    tm := time.Date(2016, 9, 22, 23, 4, 26, 0, time.UTC)
+    if s, e := Strftime(tm, "%F shift %! %_3! %#!"); e != nil || s != "2016-09-22 shift C   C c" {
+        t.Errorf("Strftime(/%v/, '%%F shift %%!') returned '%s' (%v)", tm, s, e)
+    }
+    if s := MustCompile("%!%H").Format(tm); s != "C23" {
+        t.Errorf("Format(/%v/, '%%!%%H') returned '%s'", tm, s)
+    }
+    var validate = func(val string, format string, result time.Time) {
+        if r, e := Strptime(val, format); e != nil || r != result {
+            t.Errorf("Strptime('%s', '%s') should return (%v) but not (%v) (%v)", val, format, result, r, e)
+        }
+    }
+    validate("2016-09-22 b", "%F %!", time.Date(2016, 9, 22, 14, 0, 0, 0, time.UTC))
+    validate("2016-09-22 23:04 C", "%F %H:%M %!", time.Date(2016, 9, 22, 23, 4, 0, 0, time.UTC))
+    if _, e := Strptime("2016-09-22 D", "%F %!"); !errors.Is(e, ErrNoMatch) {
+        t.Errorf("Strptime('2016-09-22 D', '%%F %%!') should fail with ErrNoMatch but not (%v)", e)
+    }
+}
+
+func TestDialect(t *testing.T) {
+    // Two libraries giving %@ different meanings.
+    var quarters, halves Dialect
+    if e := quarters.Register('@', Directive{
+        Format: func(tm time.Time) string { return "Q" + strconv.Itoa(int(tm.Month()+2)/3) },
+        ParsePattern: "Q[1-4]",
+        Parse: func(text string, c *Components) error {
+            // The quarter starts on the first of its month.
+            c.Month, c.Day, c.Fields = time.Month(3*int(text[1]-'1')+1), 1, c.Fields|FieldMonth|FieldDay
+            return nil
+        },
+    }); e != nil {
+        t.Fatalf("Register('@') failed: %s", e)
+    }
+    if e := halves.Register('@', Directive{
+        Format: func(tm time.Time) string { return "H" + strconv.Itoa(int(tm.Month()+5)/6) },
+    }); e != nil {
+        t.Fatalf("Register('@') failed: %s", e)
+    }
+    tm := time.Date(2016, 9, 22, 6, 4, 26, 0, time.UTC)
+    if s, e := quarters.Strftime(tm, "%Y-%@"); e != nil || s != "2016-Q3" {
+        t.Errorf("quarters.Strftime(/%v/, '%%Y-%%@') returned '%s' (%v)", tm, s, e)
+    }
+    if s, e := halves.Strftime(tm, "%Y-%@"); e != nil || s != "2016-H2" {
+        t.Errorf("halves.Strftime(/%v/, '%%Y-%%@') returned '%s' (%v)", tm, s, e)
+    }
+    if _, e := Strftime(tm, "%Y-%@"); !errors.Is(e, ErrUnknownDirective) {
+        t.Errorf("Strftime(/%v/, '%%Y-%%@') should fail with ErrUnknownDirective but not (%v)", tm, e)
+    }
+    if r, e := quarters.Strptime("2016-Q3", "%Y-%@"); e != nil || r != time.Date(2016, 7, 1, 0, 0, 0, 0, time.UTC) {
+        t.Errorf("quarters.Strptime('2016-Q3', '%%Y-%%@') returned (%v) (%v)", r, e)
+    }
+    if _, e := halves.Strptime("2016-H2", "%Y-%@"); !errors.Is(e, ErrUnsupportedDirective) {
+        t.Errorf("halves.Strptime('2016-H2', '%%Y-%%@') should fail with ErrUnsupportedDirective but not (%v)", e)
+    }
+    f, e := quarters.Compile("%@ %B")
+    if e != nil {
+        t.Fatalf("quarters.Compile('%%@ %%B') failed: %s", e)
+    }
+    if s := f.WithLocale(French).Format(tm); s != "Q3 septembre" {
+        t.Errorf("Format(/%v/, '%%@ %%B') in French returned '%s'", tm, s)
+    }
+    if c, e := f.WithLocale(French).ParseComponents("Q3 septembre"); e != nil || c.Month != time.September {
+        t.Errorf("ParseComponents('Q3 septembre', '%%@ %%B') returned (%v) (%v)", c, e)
+    }
+}
+
+func TestTwelveHourClock(t *testing.T) {
+    for _, c := range []struct {
+        hour int
+        result string
+    }{
+        {0, "12 12 AM am"},
+        {6, "06  6 AM am"},
+        {11, "11 11 AM am"},
+        {12, "12 12 PM pm"},
+        {13, "01  1 PM pm"},
+        {23, "11 11 PM pm"},
+    } {
+        tm := time.Date(2016, 9, 22, c.hour, 30, 0, 0, time.UTC)
+        if s, e := Strftime(tm, "%I %l %p %P"); e != nil || s != c.result {
+            t.Errorf("Strftime(/%v/, '%%I %%l %%p %%P') should return '%s' but not '%s' (%s)", tm, c.result, s, e)
+        }
+        if r, e := Strptime(c.result, "%I %l %p %P"); e != nil || r.Hour() != c.hour {
+            t.Errorf("Strptime('%s', '%%I %%l %%p %%P') should return hour %d but not (%v) (%s)", c.result, c.hour, r, e)
+        }
+    }
+
+    var validate = func(val string, format string, l *Locale, hour int) {
+        if tm, e := StrptimeLocale(val, format, l); e != nil || tm != time.Date(0, 0, 0, hour, 30, 0, 0, time.UTC) {
+            t.Errorf("StrptimeLocale('%s', '%s') should return %02d:30 but not (%v) (%s)", val, format, hour, tm, e)
+        }
+    }
+    validate("12:30 AM", "%I:%M %p", English, 0)
+    validate("12:30 PM", "%I:%M %p", English, 12)
+    validate("12:30", "%I:%M", English, 0)
+    validate("12:30", "%H:%M", English, 12)
+    validate("18:30 PM", "%H:%M %p", English, 18)
+    // %p only shifts the hour of %I, not that of %H.
+    validate("05:30 PM", "%H:%M %p", English, 5)
+    validate("00:30 pm", "%H:%M %P", English, 0)
+    validate("6:30 a.m.", "%I:%M %p", English, 6)
+    validate("6:30 P.M.", "%I:%M %p", English, 18)
+    validate("6:30 p. m.", "%I:%M %p", English, 18)
+    validate("6:30pm", "%I:%M%p", English, 18)
+    validate("6:30 P", "%I:%M %p", English, 18)
+    validate("6:30a", "%I:%M%P", English, 6)
+    validate("6:30 PM", "%I:%M %P", English, 18)
+    validate("6:30 pm", "%I:%M %p", Spanish, 18)
+    validate("6:30 p.m.", "%I:%M %p", Spanish, 18)
+    validate("6:30 p. m.", "%I:%M %p", Spanish, 18)
+    validate("午後6:30", "%p%I:%M", Japanese, 18)
+    if tm, e := StrptimeWithOptions("6:30 p.m.", "%I:%M %P", ParseOptions{CaseSensitive: true}); e != nil || tm.Hour() != 18 {
+        t.Errorf("StrptimeWithOptions('6:30 p.m.', '%%I:%%M %%P') should return 18:30 but not (%v) (%s)", tm, e)
+    }
+    if tm, e := StrptimeWithOptions("6:30 P.M.", "%I:%M %P", ParseOptions{CaseSensitive: true}); e == nil {
+        t.Errorf("StrptimeWithOptions('6:30 P.M.', '%%I:%%M %%P') should fail but returned (%v)", tm)
+    }
+}
+
+func TestStrptimeConsistency(t *testing.T) {
+    var validate = func(val string, format string, opts ParseOptions, result time.Time) {
+        if tm, e := StrptimeWithOptions(val, format, opts); e != nil || tm != result {
+            t.Errorf("StrptimeWithOptions('%s', '%s') should return /%v/ but not (%v) (%s)", val, format, result, tm, e)
+        }
+    }
+    var invalidate = func(val string, format string, opts ParseOptions) {
+        if tm, e := StrptimeWithOptions(val, format, opts); !errors.Is(e, ErrInconsistent) {
+            t.Errorf("StrptimeWithOptions('%s', '%s') should fail with ErrInconsistent but returned (%v) (%v)", val, format, tm, e)
+        }
+    }
+    sep22 := time.Date(2016, 9, 22, 0, 0, 0, 0, time.UTC)
+    validate("2016 266", "%Y %j", ParseOptions{}, sep22)
+    validate("266 2016", "%j %Y", ParseOptions{}, sep22)
+    validate("2016 366", "%Y %j", ParseOptions{}, time.Date(2016, 12, 31, 0, 0, 0, 0, time.UTC))
+    validate("Thu 2016-09-22", "%a %Y-%m-%d", ParseOptions{}, sep22)
+    validate("Thursday 2016-09-22", "%A %Y-%m-%d", ParseOptions{}, sep22)
+    validate("4 2016-09-22 266", "%w %Y-%m-%d %j", ParseOptions{}, sep22)
+    validate("Thu Sep 22 00:00:00 2016", "%c", ParseOptions{}, sep22)
+    validate("2016-09-22 22", "%Y-%m-%d %e", ParseOptions{}, sep22)
+    validate("2016-W38-4 Sep 22", "%G-W%V-%u %b %d", ParseOptions{}, sep22)
+    validate("Thu 00:00", "%a %H:%M", ParseOptions{}, time.Date(0, 0, 0, 0, 0, 0, 0, time.UTC))
+    invalidate("Mon 2016-09-22", "%a %Y-%m-%d", ParseOptions{})
+    invalidate("Monday 2016-09-22", "%A %Y-%m-%d", ParseOptions{})
+    invalidate("1 2016-09-22", "%u %Y-%m-%d", ParseOptions{})
+    invalidate("2016-09-22 265", "%Y-%m-%d %j", ParseOptions{})
+    invalidate("2016-W38-4 Sep 23", "%G-W%V-%u %b %d", ParseOptions{})
+    invalidate("06 07", "%H %H", ParseOptions{})
+    invalidate("Sep 2016-10-01", "%b %Y-%m-%d", ParseOptions{})
+    invalidate("Thu Monday", "%a %A", ParseOptions{})
+    validate("1474502400 2016-09-22", "%s %F", ParseOptions{}, sep22)
+    validate("20 2016-09-22", "%C %F", ParseOptions{}, sep22)
+    invalidate("1474524266 2015", "%s %Y", ParseOptions{})
+    invalidate("2015 1474524266", "%Y %s", ParseOptions{})
+    invalidate("1474524266 07", "%s %H", ParseOptions{})
+    invalidate("19 2016", "%C %Y", ParseOptions{})
+    invalidate("19 1474524266", "%C %s", ParseOptions{})
+    ref := ParseOptions{Reference: time.Date(2015, 6, 1, 0, 0, 0, 0, time.UTC)}
+    validate("19", "%C", ref, time.Date(1900, 6, 1, 0, 0, 0, 0, time.UTC))
+    validate("19 16", "%C %y", ref, time.Date(1916, 6, 1, 0, 0, 0, 0, time.UTC))
+    if _, e := StrptimeWithOptions("2015 366", "%Y %j", ParseOptions{Strict: true}); !errors.Is(e, ErrOutOfRange) {
+        t.Errorf("StrptimeWithOptions('2015 366', '%%Y %%j', strict) should fail with ErrOutOfRange but not (%v)", e)
+    }
+}
+
+func TestStrptimeCaseAndSpace(t *testing.T) {
+    var validate = func(val string, format string, opts ParseOptions, result time.Time) {
+        if tm, e := StrptimeWithOptions(val, format, opts); e != nil || tm != result {
+            t.Errorf("StrptimeWithOptions('%s', '%s') should return /%v/ but not (%v) (%s)", val, format, result, tm, e)
+        }
+    }
+    var invalidate = func(val string, format string, opts ParseOptions) {
+        if tm, e := StrptimeWithOptions(val, format, opts); e == nil {
+            t.Errorf("StrptimeWithOptions('%s', '%s') should fail but returned (%v)", val, format, tm)
+        }
+    }
+    sep22 := time.Date(2016, 9, 22, 0, 0, 0, 0, time.UTC)
+    pm := time.Date(0, 0, 0, 18, 4, 0, 0, time.UTC)
+    validate("22 SEP 2016", "%d %b %Y", ParseOptions{}, sep22)
+    validate("22 sep 2016", "%d %b %Y", ParseOptions{}, sep22)
+    validate("22 SEPTEMBER 2016", "%d %B %Y", ParseOptions{}, sep22)
+    validate("22 september 2016", "%d %^B %Y", ParseOptions{}, sep22)
+    validate("06:04 pm", "%I:%M %p", ParseOptions{}, pm)
+    validate("06:04 PM", "%I:%M %P", ParseOptions{}, pm)
+    validate("2016-09-22t00:00", "%Y-%m-%dT%H:%M", ParseOptions{}, sep22)
+    invalidate("22 SEP 2016", "%d %b %Y", ParseOptions{CaseSensitive: true})
+    invalidate("06:04 pm", "%I:%M %p", ParseOptions{CaseSensitive: true})
+    validate("06:04 pm", "%I:%M %P", ParseOptions{CaseSensitive: true}, pm)
+    if tm, e := StrptimeLocale("22 DÉCEMBRE 2016", "%d %B %Y", French); e != nil || tm != time.Date(2016, 12, 22, 0, 0, 0, 0, time.UTC) {
+        t.Errorf("StrptimeLocale('22 DÉCEMBRE 2016', French) failed (%v) (%s)", tm, e)
+    }
+
+    validate("22  Sep\t2016", "%d %b %Y", ParseOptions{}, sep22)
+    validate("22\n Sep 2016", "%d %b %Y", ParseOptions{Strict: true}, sep22)
+    validate("Sep  2 2016", "%b %e %Y", ParseOptions{Strict: true}, time.Date(2016, 9, 2, 0, 0, 0, 0, time.UTC))
+    invalidate("22Sep 2016", "%d %b %Y", ParseOptions{})
+    invalidate("22  Sep 2016", "%d %b %Y", ParseOptions{ExactSpace: true})
+    validate("22 Sep 2016", "%d %b %Y", ParseOptions{ExactSpace: true, CaseSensitive: true}, sep22)
+}
+
+// restoreZones puts back the zone settings as they are now once t ends,
+// so that a test changing them leaves them alone even if it fails.
+func restoreZones(t *testing.T) {
+    zones.once.Do(loadZoneAbbreviations)
+    zones.Lock()
+    defer zones.Unlock()
+    byAbbr, regions := zones.byAbbr, zones.regions
+    pinned, aliases := map[string]string{}, map[string]string{}
+    for k, v := range zones.pinned {
+        pinned[k] = v
+    }
+    for k, v := range zones.aliases {
+        aliases[k] = v
+    }
+    t.Cleanup(func() {
+        zones.Lock()
+        zones.byAbbr, zones.regions, zones.pinned, zones.aliases = byAbbr, regions, pinned, aliases
+        zones.Unlock()
+    })
+}
+
+func TestResolveZone(t *testing.T) {
+    restoreZones(t)
+    var validate = func(name string, abbr string, offset int) {
+        loc, e := ResolveZone(name)
+        if e != nil {
+            t.Errorf("ResolveZone('%s') failed: %s", name, e)
+            return
+        }
+        n, o := time.Date(2016, 1, 15, 12, 0, 0, 0, loc).Zone()
+        if n != abbr || o != offset {
+            t.Errorf("ResolveZone('%s') should be %s%+d but not (%s%+d)", name, abbr, offset, n, o)
+        }
+    }
+    validate("UTC", "UTC", 0)
+    validate("EST", "EST", -5*3600)
+    validate("CEST", "CEST", 2*3600)
+    validate("PDT", "PDT", -7*3600)
+    validate("IST", "IST", 5*3600+1800)
+    validate("CST", "CST", -6*3600)
+    validate("US/Eastern", "EST", -5*3600)
+    validate("Asia/Shanghai", "CST", 8*3600)
+
+    PreferZoneRegions("Europe")
+    validate("IST", "IST", 3600)
+    PreferZoneRegions("Asia/Shanghai")
+    validate("CST", "CST", 8*3600)
+    PreferZoneRegions()
+    validate("IST", "IST", 5*3600+1800)
+
+    if e := SetZoneAbbreviation("IST", "Asia/Jerusalem"); e != nil {
+        t.Fatalf("SetZoneAbbreviation('IST', 'Asia/Jerusalem') failed: %s", e)
+    }
+    validate("IST", "IST", 2*3600)
+    zones.Lock()
+    delete(zones.pinned, "IST")
+    zones.Unlock()
+    if e := SetZoneAbbreviation("IST", "America/Chicago"); e == nil {
+        t.Errorf("SetZoneAbbreviation('IST', 'America/Chicago') should fail")
+    }
+
+    RegisterZoneAlias("Company/HQ", "Europe/Berlin")
+    validate("Company/HQ", "CET", 3600)
+    if _, e := ResolveZone("XYZT"); e == nil {
+        t.Errorf("ResolveZone('XYZT') should fail")
+    }
+
+    // Without a tzdata directory to build the table from, as on hosts with
+    // only zoneinfo.zip or time/tzdata, the default zones still resolve.
+    zones.Lock()
+    zones.byAbbr = map[string][]zoneCandidate{}
+    zones.Unlock()
+    validate("PST", "PST", -8*3600)
+    validate("IST", "IST", 5*3600+1800)
+}
+
+func TestStrptimeZoneAbbreviation(t *testing.T) {
+    ny, _ := time.LoadLocation("America/New_York")
+    paris, _ := time.LoadLocation("Europe/Paris")
+    format := "%Y-%m-%d %H:%M:%S %Z"
+    for _, tm := range []time.Time{
+        time.Date(2016, 1, 22, 6, 4, 26, 0, ny),
+        time.Date(2016, 9, 22, 6, 4, 26, 0, ny),
+        time.Date(2016, 9, 22, 6, 4, 26, 0, paris),
+    } {
+        s, _ := Strftime(tm, format)
+        r, e := Strptime(s, format)
+        if e != nil || !r.Equal(tm) {
+            t.Errorf("Strptime('%s', '%s') should return /%v/ but not (%v) (%s)", s, format, tm, r, e)
+            continue
+        }
+        if back, _ := Strftime(r, format); back != s {
+            t.Errorf("Strftime(Strptime('%s')) should round-trip but not (%s)", s, back)
+        }
+    }
+    if r, e := Strptime("2016-09-22 06:04:26 US/Eastern", format); e != nil || !r.Equal(time.Date(2016, 9, 22, 6, 4, 26, 0, ny)) {
+        t.Errorf("Strptime('2016-09-22 06:04:26 US/Eastern') failed (%v) (%s)", r, e)
+    }
+}
+
+func TestWeekNumbers(t *testing.T) {
+    var cases = []struct {
+        date, result string
+    }{
+        {"2016-01-01", "00 00 2015-W53-5 5"},
+        {"2017-01-01", "01 00 2016-W52-7 0"},
+        {"2018-01-01", "00 01 2018-W01-1 1"},
+        {"2016-09-22", "38 38 2016-W38-4 4"},
+        {"2020-12-31", "52 52 2020-W53-4 4"},
+        {"2021-01-03", "01 00 2020-W53-7 0"},
+        {"2019-12-30", "52 52 2020-W01-1 1"},
+    }
+    for _, c := range cases {
+        tm, e := Strptime(c.date, "%Y-%m-%d")
+        if e != nil {
+            t.Fatalf("Strptime('%s') failed: %s", c.date, e)
+        }
+        if s, e := Strftime(tm, "%U %W %G-W%V-%u %w"); e != nil || s != c.result {
+            t.Errorf("Strftime(/%v/, '%%U %%W %%G-W%%V-%%u %%w') should return '%s' but not (%s) (%s)", tm, c.result, s, e)
+        }
+        for _, format := range []string{"%G-W%V-%u", "%Y %U %w", "%Y %W %w", "%g-W%V-%u"} {
+            s, _ := Strftime(tm, format)
+            if r, e := Strptime(s, format); e != nil || r != tm {
+                t.Errorf("Strptime('%s', '%s') should return /%v/ but not (%v) (%s)", s, format, tm, r, e)
+            }
+        }
+    }
+    loc, _ := time.LoadLocation("UTC")
+    if r, e := Strptime("2016-W38", "%G-W%V"); e != nil || r != time.Date(2016, 9, 19, 0, 0, 0, 0, loc) {
+        t.Errorf("Strptime('2016-W38', '%%G-W%%V') should return the Monday of the week but not (%v) (%s)", r, e)
+    }
+    if r, e := Strptime("2017 00", "%Y %U"); e != nil || r != time.Date(2017, 1, 1, 0, 0, 0, 0, loc) {
+        t.Errorf("Strptime('2017 00', '%%Y %%U') should return the Sunday of the week but not (%v) (%s)", r, e)
+    }
+}
+
 func TestStrptime(t *testing.T) {
     var validate = func(val string, format string, result time.Time) {
         if tm, e := Strptime(val, format); e != nil || tm != result {
@@ -59,6 +1194,90 @@ func TestStrptime(t *testing.T) {
     validate("2016-Sep-22T06:04:26.000321 UTC", "%Y-%b-%dT%H:%M:%S.%f %Z", tm)
 }
 
+func TestStrftimeLocale(t *testing.T) {
+    var validate = func(tm time.Time, format string, l *Locale, result string) {
+        if s, e := StrftimeLocale(tm, format, l); e != nil || s != result {
+            t.Errorf("StrftimeLocale(/%v/, '%s', %s) should return '%s' but not (%s) (%s)\n", tm, format, l.Name, result, e, s)
+        }
+    }
+    loc, _ := time.LoadLocation("UTC")
+    tm := time.Unix(1474524266, 321).In(loc)
+    validate(tm, "%A %d %B %Y", French, "jeudi 22 septembre 2016")
+    validate(tm, "%a %d %b", French, "jeu. 22 sept.")
+    validate(tm, "%c", French, "jeu. 22 sept. 2016 06:04:26")
+    validate(tm, "%x", German, "22.09.2016")
+    validate(tm, "%A, %d. %B %Y", German, "Donnerstag, 22. September 2016")
+    validate(tm, "%x %p", Spanish, "22/09/16 a. m.")
+    validate(tm, "%c", Japanese, "2016年09月22日 06時04分26秒")
+    validate(tm, "%p%I時", Japanese, "午前06時")
+    validate(tm, "%x %A", Chinese, "2016年09月22日 星期四")
+    validate(tm, "%c", English, "Thu Sep 22 06:04:26 2016")
+
+    for _, name := range []string{"fr_FR", "fr-FR", "fr_fr.UTF-8", "fr"} {
+        if l, ok := LookupLocale(name); !ok || l != French {
+            t.Errorf("LookupLocale('%s') should find fr_FR but not (%v)", name, l)
+        }
+    }
+    if _, ok := LookupLocale("xx_XX"); ok {
+        t.Errorf("LookupLocale('xx_XX') should fail")
+    }
+    custom := *English
+    custom.Name = "en_GB"
+    custom.DateFormat = "%d/%m/%Y"
+    RegisterLocale(&custom)
+    if l, ok := LookupLocale("en-GB"); !ok || l != &custom {
+        t.Errorf("LookupLocale('en-GB') should find the registered locale but not (%v)", l)
+    }
+    if l, _ := LookupLocale("en"); l != &custom {
+        t.Errorf("LookupLocale('en') should find the first en locale by name but not (%v)", l.Name)
+    }
+    validate(tm, "%x", &custom, "22/09/2016")
+    recursive := custom
+    recursive.DateFormat = "%x"
+    if _, e := StrftimeLocale(tm, "%x", &recursive); e == nil {
+        t.Errorf("StrftimeLocale with a recursive %%x pattern should fail")
+    }
+    if s := MustCompile("%B").WithLocale(German).Format(tm); s != "September" {
+        t.Errorf("Format.WithLocale(German) should return 'September' but not (%s)", s)
+    }
+}
+
+func TestStrptimeLocale(t *testing.T) {
+    var validate = func(val string, format string, l *Locale, result time.Time) {
+        if tm, e := StrptimeLocale(val, format, l); e != nil || tm != result {
+            t.Errorf("StrptimeLocale('%s', '%s', %s) should return /%v/ but not (%v) (%s) \n", val, format, l.Name, result, tm, e)
+        }
+    }
+    loc, _ := time.LoadLocation("UTC")
+    validate("22 septembre 2016", "%d %B %Y", French, time.Date(2016, 9, 22, 0, 0, 0, 0, loc))
+    validate("22 sept. 2016", "%d %b %Y", French, time.Date(2016, 9, 22, 0, 0, 0, 0, loc))
+    validate("22. März 2016", "%d. %B %Y", German, time.Date(2016, 3, 22, 0, 0, 0, 0, loc))
+    validate("22/09/16 06:04:26", "%x %X", Spanish, time.Date(2016, 9, 22, 6, 4, 26, 0, loc))
+    validate("2016年9月22日 午後 06時", "%Y年%B%d日 %p %I時", Japanese, time.Date(2016, 9, 22, 18, 0, 0, 0, loc))
+    validate("2016年12月22日", "%Y年%b%d日", Japanese, time.Date(2016, 12, 22, 0, 0, 0, 0, loc))
+    validate("2016年09月22日 上午", "%x %p", Chinese, time.Date(2016, 9, 22, 0, 0, 0, 0, loc))
+
+    if _, e := StrptimeLocale("22 September 2016", "%d %B %Y", French); e == nil {
+        t.Errorf("StrptimeLocale('22 September 2016', French) should fail")
+    }
+    if tm, l, e := StrptimeAnyLocale("22 septembre 2016", "%d %B %Y"); e != nil || l != French || tm != time.Date(2016, 9, 22, 0, 0, 0, 0, loc) {
+        t.Errorf("StrptimeAnyLocale('22 septembre 2016') should match French but not (%v) (%v) (%s)", tm, l, e)
+    }
+    if _, l, e := StrptimeAnyLocale("22 Sep 2016", "%d %b %Y"); e != nil || l != English {
+        t.Errorf("StrptimeAnyLocale('22 Sep 2016') should prefer English but not (%v) (%s)", l, e)
+    }
+    if _, l, e := StrptimeAnyLocale("22 sep 2016", "%d %b %Y", "fr", "es"); e != nil || l != Spanish {
+        t.Errorf("StrptimeAnyLocale('22 sep 2016', fr, es) should match Spanish but not (%v) (%s)", l, e)
+    }
+    if _, _, e := StrptimeAnyLocale("22 Sep 2016", "%d %b %Y", "xx"); e == nil {
+        t.Errorf("StrptimeAnyLocale with an unknown locale should fail")
+    }
+    f := MustCompile("%d %B %Y").WithLocale(German)
+    if tm, e := f.Parse("22 Dezember 2016"); e != nil || tm != time.Date(2016, 12, 22, 0, 0, 0, 0, loc) {
+        t.Errorf("Format.WithLocale(German).Parse('22 Dezember 2016') failed (%v) (%s)", tm, e)
+    }
+}
+
 func BenchmarkStrftime(b *testing.B) {
     loc, _ := time.LoadLocation("UTC")
     tm := time.Unix(1474524266, 321).In(loc)
@@ -67,8 +1286,109 @@ func BenchmarkStrftime(b *testing.B) {
     }
 }
 
+func TestAppendStrftime(t *testing.T) {
+    loc, _ := time.LoadLocation("UTC")
+    tm := time.Unix(1474524266, 321).In(loc)
+    format := "%Y-%m-%dT%H:%M:%S.%f %z %Z %p %P %b %B %a %A %j"
+    result := "2016-09-22T06:04:26.000000 +0000 UTC AM am Sep September Thu Thursday 266"
+    b, e := AppendStrftime([]byte("ts="), tm, format)
+    if e != nil || string(b) != "ts="+result {
+        t.Errorf("AppendStrftime(/%v/, '%s') should return 'ts=%s' but not (%s) (%s)", tm, format, result, e, b)
+    }
+    w := bytes.Buffer{}
+    if n, e := WriteStrftime(&w, tm, format); e != nil || n != len(result) || w.String() != result {
+        t.Errorf("WriteStrftime(/%v/, '%s') should write '%s' but not (%s) (%s)", tm, format, result, e, w.String())
+    }
+    buf := make([]byte, 0, 128)
+    allocs := testing.AllocsPerRun(100, func() {
+        buf, _ = AppendStrftime(buf[:0], tm, format)
+    })
+    if allocs != 0 {
+        t.Errorf("AppendStrftime should not allocate but did %v times", allocs)
+    }
+    f := MustCompile(format)
+    allocs = testing.AllocsPerRun(100, func() {
+        buf = f.AppendFormat(buf[:0], tm)
+    })
+    if allocs != 0 {
+        t.Errorf("Format.AppendFormat should not allocate but did %v times", allocs)
+    }
+}
+
+func BenchmarkAppendStrftime(b *testing.B) {
+    loc, _ := time.LoadLocation("UTC")
+    tm := time.Unix(1474524266, 321).In(loc)
+    buf := make([]byte, 0, 128)
+    b.ReportAllocs()
+    for n := 0; n < b.N; n++ {
+        buf, _ = AppendStrftime(buf[:0], tm, "%Y-%m-%dT%H:%M:%S %z %Z %p %b %B %a %A")
+    }
+}
+
 func BenchmarkStrptime(b *testing.B) {
     for n := 0; n < b.N; n++ {
         _, _ = Strptime("2016-Sep-22T06:04:26.000321 UTC", "%Y-%b-%dT%H:%M:%S.%f %Z")
     }
 }
+
+func TestCompile(t *testing.T) {
+    loc, _ := time.LoadLocation("UTC")
+    tm := time.Unix(1474524266, 0).In(loc)
+    f, e := Compile("%Y-%m-%dT%H:%M:%S")
+    if e != nil {
+        t.Fatalf("Compile failed: %s", e)
+    }
+    if f.String() != "%Y-%m-%dT%H:%M:%S" {
+        t.Errorf("String() should return the source format but not (%s)", f.String())
+    }
+    if s := f.Format(tm); s != "2016-09-22T06:04:26" {
+        t.Errorf("Format(/%v/) should return '2016-09-22T06:04:26' but not (%s)", tm, s)
+    }
+    if r, e := f.Parse("2016-09-22T06:04:26"); e != nil || r != tm {
+        t.Errorf("Parse('2016-09-22T06:04:26') should return /%v/ but not (%v) (%s)", tm, r, e)
+    }
+    if _, e := Compile("%Y-%~"); e == nil {
+        t.Errorf("Compile('%%Y-%%~') should fail")
+    }
+    f = MustCompile("%Y %j")
+    if s := f.Format(tm); s != "2016 266" {
+        t.Errorf("Format(/%v/) should return '2016 266' but not (%s)", tm, s)
+    }
+    if r, e := f.Parse("2016 266"); e != nil || r != time.Date(2016, 9, 22, 0, 0, 0, 0, time.UTC) {
+        t.Errorf("Parse('2016 266') with '%%Y %%j' should return 2016-09-22 but not (%v) (%s)", r, e)
+    }
+
+    // A directive that fails stops Format and is reported by Strftime.
+    broken := *English
+    broken.DateFormat = "%c"
+    f = MustCompile("%Y %x").WithLocale(&broken)
+    if s := f.Format(tm); s != "2016 " {
+        t.Errorf("Format(/%v/) with a recursive %%x should return '2016 ' but not (%s)", tm, s)
+    }
+    if s, e := f.Strftime(tm); e == nil {
+        t.Errorf("Strftime(/%v/) with a recursive %%x should fail but returned '%s'", tm, s)
+    } else if fe, ok := e.(*FormatError); !ok || fe.Directive != "%x" {
+        t.Errorf("Strftime(/%v/) with a recursive %%x should fail at %%x but not (%v)", tm, e)
+    }
+    if _, e := StrftimeLocale(tm, "%Y %x", &broken); e == nil {
+        t.Errorf("StrftimeLocale(/%v/, '%%Y %%x') with a recursive %%x should fail", tm)
+    } else if fe, ok := e.(*FormatError); !ok || fe.Directive != "%x" {
+        t.Errorf("StrftimeLocale(/%v/, '%%Y %%x') with a recursive %%x should fail at %%x but not (%v)", tm, e)
+    }
+}
+
+func BenchmarkFormat(b *testing.B) {
+    loc, _ := time.LoadLocation("UTC")
+    tm := time.Unix(1474524266, 321).In(loc)
+    f := MustCompile("%Y-%m-%dT%H:%M:%S %z %Z %p %b %B %a %A")
+    for n := 0; n < b.N; n++ {
+        _ = f.Format(tm)
+    }
+}
+
+func BenchmarkParse(b *testing.B) {
+    f := MustCompile("%Y-%b-%dT%H:%M:%S.%f %Z")
+    for n := 0; n < b.N; n++ {
+        _, _ = f.Parse("2016-Sep-22T06:04:26.000321 UTC")
+    }
+}
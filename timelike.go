@@ -0,0 +1,169 @@
+package timefmt
+
+import (
+	"math"
+	"reflect"
+	"time"
+)
+
+// A Timelike is a value StrftimeValue can format: a time.Time, a Date,
+// TimeOfDay or DateTime, a UnixSeconds or UnixMillis, an integer of any
+// type, which counts seconds as UnixSeconds does, or any type with some of
+// these methods of time.Time:
+//
+//	Date() (year int, month time.Month, day int)
+//	Clock() (hour, min, sec int)
+//	Nanosecond() int
+//	Zone() (name string, offset int)
+//
+// Directives of the date need Date and those of the time of day need Clock;
+// formatting fails with ErrMissingField for a value without them. Without
+// Nanosecond the fraction of the second is zero, and without Zone the value
+// is naive: %z and %Z are empty and %s counts it as UTC. Clock may return
+// second 60 for a leap second. A value with neither Date, Clock nor Zone,
+// other than an integer, fails with ErrUnsupportedValue, as does an unsigned
+// integer beyond math.MaxInt64 or a nil pointer, whose methods could not be
+// called.
+type Timelike interface{}
+
+// UnixSeconds is a Timelike of the seconds since 1970-01-01 00:00:00 UTC,
+// formatted in UTC.
+type UnixSeconds int64
+
+// UnixMillis is a Timelike of the milliseconds since 1970-01-01 00:00:00
+// UTC, formatted in UTC.
+type UnixMillis int64
+
+type dateValue interface {
+	Date() (year int, month time.Month, day int)
+}
+
+type clockValue interface {
+	Clock() (hour, min, sec int)
+}
+
+type nanosecondValue interface {
+	Nanosecond() int
+}
+
+type zoneValue interface {
+	Zone() (name string, offset int)
+}
+
+// directiveNeeds holds the fields each directive needs, as the modes that
+// say they are missing. Composite and locale directives need the fields of
+// their patterns, which are checked again as those are expanded.
+var directiveNeeds = map[rune]formatMode{
+	'a': formatNoDate, 'A': formatNoDate, 'w': formatNoDate, 'u': formatNoDate,
+	'd': formatNoDate, 'e': formatNoDate, 'j': formatNoDate,
+	'b': formatNoDate, 'B': formatNoDate, 'h': formatNoDate, 'm': formatNoDate,
+	'y': formatNoDate, 'Y': formatNoDate, 'C': formatNoDate,
+	'g': formatNoDate, 'G': formatNoDate, 'V': formatNoDate,
+	'U': formatNoDate, 'W': formatNoDate, 'J': formatNoDate, 'i': formatNoDate,
+	'q': formatNoDate, 'v': formatNoDate, 'K': formatNoDate, 'Q': formatNoDate, 'o': formatNoDate,
+	'D': formatNoDate, 'F': formatNoDate, 'x': formatNoDate,
+	'H': formatNoClock, 'k': formatNoClock, 'I': formatNoClock, 'l': formatNoClock,
+	'p': formatNoClock, 'P': formatNoClock, 'M': formatNoClock, 'S': formatNoClock,
+	'f': formatNoClock, 'N': formatNoClock, 'L': formatNoClock,
+	'R': formatNoClock, 'T': formatNoClock, 'X': formatNoClock, 'r': formatNoClock,
+	's': formatNoDate | formatNoClock, 'c': formatNoDate | formatNoClock,
+	'+': formatNoDate | formatNoClock,
+}
+
+// StrftimeValue is like Strftime but formats any Timelike value. A directive
+// that needs a field v does not have, such as %H for a Date, fails with a
+// FormatError wrapping ErrMissingField.
+func StrftimeValue(v Timelike, format string) (string, error) {
+	t, mode, ok := timeOf(v)
+	if !ok {
+		return "", ErrUnsupportedValue
+	}
+	var buf [64]byte
+	b, e := appendStrftime(buf[:0], t, format, English, mode, &registered)
+	if e != nil {
+		return "", e
+	}
+	return string(b), nil
+}
+
+// timeOf returns a time holding the fields of v and the mode that says
+// which fields v does not have, and false if v has none of them. The date of
+// a value without one is 1900-01-01, as in Python.
+func timeOf(v Timelike) (time.Time, formatMode, bool) {
+	switch v := v.(type) {
+	case time.Time:
+		return v, 0, true
+	case UnixSeconds:
+		return time.Unix(int64(v), 0).UTC(), 0, true
+	case UnixMillis:
+		return time.UnixMilli(int64(v)).UTC(), 0, true
+	case int:
+		return time.Unix(int64(v), 0).UTC(), 0, true
+	case int64:
+		return time.Unix(v, 0).UTC(), 0, true
+	case int32:
+		return time.Unix(int64(v), 0).UTC(), 0, true
+	case uint:
+		if uint64(v) > math.MaxInt64 {
+			return time.Time{}, 0, false
+		}
+		return time.Unix(int64(v), 0).UTC(), 0, true
+	case uint64:
+		if uint64(v) > math.MaxInt64 {
+			return time.Time{}, 0, false
+		}
+		return time.Unix(int64(v), 0).UTC(), 0, true
+	case uint32:
+		return time.Unix(int64(v), 0).UTC(), 0, true
+	case DateTime:
+		t, mode := civilTime(v.Date, v.TimeOfDay, time.UTC, formatNaive)
+		return t, mode, true
+	case Date:
+		t, mode := civilTime(v, TimeOfDay{}, time.UTC, formatNaive|formatNoClock)
+		return t, mode, true
+	case TimeOfDay:
+		t, mode := civilTime(Date{1900, time.January, 1}, v, time.UTC, formatNaive|formatNoDate)
+		return t, mode, true
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+		return time.Time{}, 0, false
+	}
+	var d Date
+	var c TimeOfDay
+	var mode formatMode
+	if dv, ok := v.(dateValue); ok {
+		d.Year, d.Month, d.Day = dv.Date()
+	} else {
+		d, mode = Date{1900, time.January, 1}, mode|formatNoDate
+	}
+	if cv, ok := v.(clockValue); ok {
+		c.Hour, c.Minute, c.Second = cv.Clock()
+		if nv, ok := v.(nanosecondValue); ok {
+			c.Nanosecond = nv.Nanosecond()
+		}
+	} else {
+		mode |= formatNoClock
+	}
+	zv, hasZone := v.(zoneValue)
+	if mode&formatNoDate != 0 && mode&formatNoClock != 0 && !hasZone {
+		return time.Time{}, 0, false
+	}
+	loc := time.UTC
+	if hasZone {
+		name, offset := zv.Zone()
+		loc = time.FixedZone(name, offset)
+	} else {
+		mode |= formatNaive
+	}
+	t, mode := civilTime(d, c, loc, mode)
+	return t, mode, true
+}
+
+// civilTime returns d at c in loc, with the mode formatting it needs: a
+// second of 60 is held as 59 and formatted as a leap second.
+func civilTime(d Date, c TimeOfDay, loc *time.Location, mode formatMode) (time.Time, formatMode) {
+	if c.Second == 60 {
+		c.Second, mode = 59, mode|formatLeap
+	}
+	return d.At(c).In(loc), mode
+}
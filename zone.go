@@ -0,0 +1,281 @@
+package timefmt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// zoneCandidate is an IANA zone that uses an abbreviation with a given offset.
+type zoneCandidate struct {
+	zone   string
+	offset int
+}
+
+// zoneDirs are searched in order for the local tzdata, as time.LoadLocation
+// does on Unix systems.
+var zoneDirs = []string{
+	"/usr/share/zoneinfo/",
+	"/usr/share/lib/zoneinfo/",
+	"/usr/lib/locale/TZ/",
+	"/etc/zoneinfo/",
+}
+
+// zoneAbbreviationsSince limits the table to abbreviations in use from this
+// year on, so that long abandoned ones do not clash with current ones.
+const zoneAbbreviationsSince = 2000
+
+// defaultZoneAbbreviations settles the best known clashes in favour of the
+// most populous zone, unless PreferZoneRegions or SetZoneAbbreviation says
+// otherwise.
+var defaultZoneAbbreviations = map[string]string{
+	"EST": "America/New_York",
+	"EDT": "America/New_York",
+	"CST": "America/Chicago",
+	"CDT": "America/Chicago",
+	"MST": "America/Denver",
+	"MDT": "America/Denver",
+	"PST": "America/Los_Angeles",
+	"PDT": "America/Los_Angeles",
+	"HST": "Pacific/Honolulu",
+	"AST": "America/Halifax",
+	"ADT": "America/Halifax",
+	"BST": "Europe/London",
+	"IST": "Asia/Kolkata",
+}
+
+// defaultZoneAliases are legacy zone names that are not always installed as
+// links in the tzdata.
+var defaultZoneAliases = map[string]string{
+	"US/Alaska":       "America/Anchorage",
+	"US/Aleutian":     "America/Adak",
+	"US/Arizona":      "America/Phoenix",
+	"US/Central":      "America/Chicago",
+	"US/Eastern":      "America/New_York",
+	"US/Hawaii":       "Pacific/Honolulu",
+	"US/Mountain":     "America/Denver",
+	"US/Pacific":      "America/Los_Angeles",
+	"Canada/Atlantic": "America/Halifax",
+	"Canada/Central":  "America/Winnipeg",
+	"Canada/Eastern":  "America/Toronto",
+	"Canada/Mountain": "America/Edmonton",
+	"Canada/Pacific":  "America/Vancouver",
+	"GB":              "Europe/London",
+	"Eire":            "Europe/Dublin",
+	"PRC":             "Asia/Shanghai",
+	"ROC":             "Asia/Taipei",
+	"ROK":             "Asia/Seoul",
+	"Japan":           "Asia/Tokyo",
+	"Singapore":       "Asia/Singapore",
+	"Hongkong":        "Asia/Hong_Kong",
+	"Israel":          "Asia/Jerusalem",
+}
+
+var zones = struct {
+	sync.RWMutex
+	once    sync.Once
+	byAbbr  map[string][]zoneCandidate // built from the local tzdata
+	pinned  map[string]string          // set by SetZoneAbbreviation
+	regions []string                   // set by PreferZoneRegions
+	aliases map[string]string          // set by RegisterZoneAlias
+}{pinned: map[string]string{}, aliases: map[string]string{}}
+
+// ResolveZone returns the location named by a %Z value. It accepts IANA
+// names ("America/New_York"), legacy aliases ("US/Eastern") and time zone
+// abbreviations ("EST", "CEST"). An abbreviation resolves to a fixed zone
+// with that name and the offset it stands for, so formatting the result
+// with %Z gives the abbreviation back.
+//
+// An abbreviation used with different offsets by several zones, like IST or
+// CST, is resolved by, in order: SetZoneAbbreviation, the regions given to
+// PreferZoneRegions, a built-in choice for the best known ones and finally
+// the offset used by most zones.
+func ResolveZone(name string) (*time.Location, error) {
+	if name == "UTC" {
+		return time.UTC, nil
+	}
+	zones.RLock()
+	alias, ok := zones.aliases[name]
+	zones.RUnlock()
+	if ok {
+		return time.LoadLocation(alias)
+	}
+	if alias, ok := defaultZoneAliases[name]; ok {
+		// Prefer the installed link, which may carry its own history.
+		if loc, e := time.LoadLocation(name); nil == e {
+			return loc, nil
+		}
+		return time.LoadLocation(alias)
+	}
+	if strings.IndexByte(name, '/') < 0 {
+		if offset, ok := lookupZoneAbbreviation(name); ok {
+			return time.FixedZone(name, offset), nil
+		}
+	}
+	return time.LoadLocation(name)
+}
+
+// SetZoneAbbreviation makes ResolveZone resolve abbr with the offset it has
+// in the IANA zone, e.g. SetZoneAbbreviation("IST", "Europe/Dublin").
+func SetZoneAbbreviation(abbr string, zone string) error {
+	loc, e := time.LoadLocation(zone)
+	if nil != e {
+		return e
+	}
+	if _, ok := zoneOffset(loc, abbr); !ok {
+		return errors.New("Zone " + zone + " does not use abbreviation " + abbr)
+	}
+	zones.Lock()
+	zones.pinned[abbr] = zone
+	zones.Unlock()
+	return nil
+}
+
+// PreferZoneRegions sets the regions whose zones win when an abbreviation is
+// ambiguous, most preferred first, e.g. PreferZoneRegions("Europe", "Asia").
+// A region is the first component of an IANA name, or a full zone name.
+// Calling it without arguments restores the default choices.
+func PreferZoneRegions(regions ...string) {
+	zones.Lock()
+	zones.regions = append([]string(nil), regions...)
+	zones.Unlock()
+}
+
+// RegisterZoneAlias makes ResolveZone accept alias as another name for the
+// IANA zone.
+func RegisterZoneAlias(alias string, zone string) {
+	zones.Lock()
+	zones.aliases[alias] = zone
+	zones.Unlock()
+}
+
+// lookupZoneAbbreviation returns the offset abbr stands for.
+func lookupZoneAbbreviation(abbr string) (int, bool) {
+	zones.once.Do(loadZoneAbbreviations)
+	zones.RLock()
+	defer zones.RUnlock()
+	if zone, ok := zones.pinned[abbr]; ok {
+		if loc, e := time.LoadLocation(zone); nil == e {
+			return zoneOffset(loc, abbr)
+		}
+	}
+	candidates := zones.byAbbr[abbr]
+	if len(candidates) == 0 {
+		// Without a tzdata directory to list, as with zoneinfo.zip or
+		// time/tzdata, only the default zones of abbreviations are known.
+		if zone, ok := defaultZoneAbbreviations[abbr]; ok {
+			if loc, e := time.LoadLocation(zone); nil == e {
+				return zoneOffset(loc, abbr)
+			}
+		}
+		return 0, false
+	}
+	for _, region := range zones.regions {
+		for _, c := range candidates {
+			if c.zone == region || strings.HasPrefix(c.zone, region+"/") {
+				return c.offset, true
+			}
+		}
+	}
+	if zone, ok := defaultZoneAbbreviations[abbr]; ok {
+		for _, c := range candidates {
+			if c.zone == zone {
+				return c.offset, true
+			}
+		}
+	}
+	count := map[int]int{}
+	best := candidates[0].offset
+	for _, c := range candidates {
+		count[c.offset]++
+		if count[c.offset] > count[best] {
+			best = c.offset
+		}
+	}
+	return best, true
+}
+
+// loadZoneAbbreviations builds the abbreviation table from every zone found
+// in the local tzdata.
+func loadZoneAbbreviations() {
+	byAbbr := map[string][]zoneCandidate{}
+	for _, name := range localZoneNames() {
+		loc, e := time.LoadLocation(name)
+		if nil != e {
+			continue
+		}
+		for abbr, offset := range zoneAbbreviations(loc) {
+			byAbbr[abbr] = append(byAbbr[abbr], zoneCandidate{zone: name, offset: offset})
+		}
+	}
+	zones.Lock()
+	zones.byAbbr = byAbbr
+	zones.Unlock()
+}
+
+// localZoneNames lists the zones of the first tzdata directory found, in
+// sorted order. The posix/ and right/ trees duplicate the others.
+func localZoneNames() []string {
+	dirs := zoneDirs
+	if zi := os.Getenv("ZONEINFO"); zi != "" {
+		dirs = append([]string{zi}, dirs...)
+	}
+	for _, dir := range dirs {
+		if fi, e := os.Stat(dir); nil != e || !fi.IsDir() {
+			continue
+		}
+		var names []string
+		filepath.Walk(dir, func(path string, fi os.FileInfo, e error) error {
+			if nil != e {
+				return nil
+			}
+			name, _ := filepath.Rel(dir, path)
+			name = filepath.ToSlash(name)
+			if fi.IsDir() {
+				if name == "posix" || name == "right" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if name[0] < 'A' || name[0] > 'Z' || strings.IndexByte(name, '.') >= 0 || name == "Factory" {
+				return nil
+			}
+			names = append(names, name)
+			return nil
+		})
+		sort.Strings(names)
+		return names
+	}
+	return nil
+}
+
+// zoneAbbreviations returns the alphabetic abbreviations loc has used since
+// zoneAbbreviationsSince with their latest offsets. Numeric ones like "+03"
+// are offsets in disguise and are left to %z.
+func zoneAbbreviations(loc *time.Location) map[string]int {
+	abbrs := map[string]int{}
+	end := time.Now().AddDate(1, 0, 0)
+	t := time.Date(zoneAbbreviationsSince, 1, 1, 0, 0, 0, 0, loc)
+	for t.Before(end) {
+		name, offset := t.Zone()
+		if name != "" && (name[0] != '+' && name[0] != '-') {
+			abbrs[name] = offset
+		}
+		_, next := t.ZoneBounds()
+		if next.IsZero() {
+			break
+		}
+		t = next
+	}
+	return abbrs
+}
+
+// zoneOffset returns the latest offset loc used with abbr.
+func zoneOffset(loc *time.Location, abbr string) (int, bool) {
+	offset, ok := zoneAbbreviations(loc)[abbr]
+	return offset, ok
+}